@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+// configUsage describes the config subcommands
+const configUsage = `Usage: talis-agent config <command> [arguments]
+
+Commands:
+  init [-force] [file]  write a commented default configuration to file or stdout
+  schema                print the JSON Schema of the configuration file
+  validate <file>       check a configuration file and report every problem found
+`
+
+// runConfigCommand runs a `talis-agent config` subcommand and returns the
+// process exit code
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, configUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "init":
+		return initConfig(args[1:], stdout, stderr)
+	case "schema":
+		return printSchema(stdout, stderr)
+	case "validate":
+		return validateConfig(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown config command %q\n\n%s", args[0], configUsage)
+		return 2
+	}
+}
+
+// validateConfig checks the configuration file in args and prints every
+// problem found, one per line
+func validateConfig(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprint(stderr, "Usage: talis-agent config validate <file>\n")
+		return 2
+	}
+	path := args[0]
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	if err := cfg.Validate(); err != nil {
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		for _, ferr := range verr.Errors {
+			fmt.Fprintf(stderr, "%s: %v\n", path, ferr)
+		}
+		fmt.Fprintf(stderr, "%s: %d problem(s) found\n", path, len(verr.Errors))
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%s: configuration is valid\n", path)
+	return 0
+}
+
+// initConfig writes the documented default configuration to the file in
+// args, or to stdout when no file is given. Existing files are only
+// replaced with -force.
+func initConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	force := fs.Bool("force", false, "overwrite an existing file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprint(stderr, "Usage: talis-agent config init [-force] [file]\n")
+		return 2
+	}
+
+	data, err := config.Template()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	if fs.NArg() == 0 {
+		if _, err := stdout.Write(data); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	path := fs.Arg(0)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644) // nolint: gosec
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(stderr, "%s already exists, use -force to overwrite it\n", path)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		fmt.Fprintf(stderr, "failed to write %s: %v\n", path, err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(stderr, "failed to write %s: %v\n", path, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Wrote default configuration to %s\n", path)
+	return 0
+}
+
+// printSchema writes the JSON Schema of the configuration file to stdout
+func printSchema(stdout, stderr io.Writer) int {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config.Schema()); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -1,31 +1,67 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/celestiaorg/talis-agent/internal/certs"
 	"github.com/celestiaorg/talis-agent/internal/config"
 	"github.com/celestiaorg/talis-agent/internal/handlers"
+	"github.com/celestiaorg/talis-agent/internal/history"
+	agenthttp "github.com/celestiaorg/talis-agent/internal/http"
+	"github.com/celestiaorg/talis-agent/internal/logging"
 	"github.com/celestiaorg/talis-agent/internal/metrics"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	// Run config subcommands such as `talis-agent config validate <file>`
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// Parse command-line flags
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the configuration file (env "+config.EnvConfigFile+")")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	overrides := config.RegisterFlags(fs)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		log.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	// Load configuration: defaults < config file < environment < flags
+	cfg, err := config.Resolve(*configPath, os.LookupEnv, overrides)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if *printConfig {
+		if err := printEffectiveConfig(cfg); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Initialize logging
+	if err := logging.InitLogger(logging.Config{
+		Level:   cfg.Logging.Level,
+		Console: cfg.Logging.Format == "text",
+	}); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+
 	// Parse metrics collection interval
 	interval, err := time.ParseDuration(cfg.Metrics.CollectionInterval)
 	if err != nil {
@@ -35,64 +71,179 @@ func main() {
 
 	// Initialize metrics collector
 	collector := metrics.NewCollector(interval)
+	diskFilter, err := metrics.NewDiskFilter(cfg.Metrics)
+	if err != nil {
+		log.Fatalf("Failed to create metrics collector: %v", err)
+	}
+	collector.SetDiskFilter(diskFilter)
 
 	// Register collector with Prometheus
 	prometheus.MustRegister(collector)
 
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName:      "Talis Agent",
-		ReadTimeout:  time.Second * 10,
-		WriteTimeout: time.Second * 10,
-	})
+	// Record the system metrics so /metrics/history can serve them
+	historyConfig := history.Config{
+		Path:      cfg.Metrics.HistoryFile,
+		Retention: retention(cfg.Metrics.RetentionDays),
+	}
+	store, err := history.Open(historyConfig)
+	if err != nil {
+		log.Printf("Starting with an empty metrics history: %v", err)
+		store = history.New(historyConfig)
+	}
+	systemRegistry := prometheus.NewRegistry()
+	systemRegistry.MustRegister(collector)
 
-	// Add middleware
-	app.Use(logger.New())
-	app.Use(recover.New())
-	app.Use(cors.New())
+	// Initialize handlers and the server
+	h := handlers.NewHandler(collector, handlers.WithHistory(store))
+	server, err := agenthttp.NewServer(cfg, h)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
 
-	// Initialize handlers
-	h := handlers.NewHandler(collector)
+	// Check in with the Talis API and push telemetry when it is configured
+	var telemetry *metrics.TelemetryClient
+	if cfg.API.BaseURL != "" {
+		telemetry, err = metrics.NewTelemetryClient(cfg, prometheus.DefaultGatherer)
+		if err != nil {
+			log.Fatalf("Failed to create telemetry client: %v", err)
+		}
+	} else {
+		log.Println("api.base_url is not set, telemetry is disabled")
+	}
 
-	// Setup routes
-	setupRoutes(app, h)
+	// Push metrics to a Prometheus remote write endpoint when configured
+	var remoteWriter *metrics.RemoteWriter
+	if cfg.RemoteWrite.URL != "" {
+		remoteWriter, err = metrics.NewRemoteWriter(cfg, prometheus.DefaultGatherer)
+		if err != nil {
+			log.Fatalf("Failed to create remote writer: %v", err)
+		}
+	}
+
+	// Export metrics to an OpenTelemetry collector when configured
+	var otlpExporter *metrics.OTLPExporter
+	if cfg.OTLP.Endpoint != "" {
+		hostInfo, err := metrics.GetHostInfo()
+		if err != nil {
+			log.Fatalf("Failed to create OTLP exporter: %v", err)
+		}
+		otlpExporter, err = metrics.NewOTLPExporter(cfg, prometheus.DefaultGatherer, hostInfo)
+		if err != nil {
+			log.Fatalf("Failed to create OTLP exporter: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Reload the configuration on SIGHUP and when the config file changes
+	watcher := config.NewWatcher(cfg, config.FindFile(*configPath, os.LookupEnv), func() (*config.Config, error) {
+		return config.Resolve(*configPath, os.LookupEnv, overrides)
+	})
+	watcher.OnReload("auth", func(cfg *config.Config) (func(), error) {
+		return server.Authenticator().Prepare(cfg.Security)
+	})
+	if reloader := server.Reloader(); reloader != nil {
+		watcher.OnReload("tls", func(cfg *config.Config) (func(), error) {
+			return reloader.Prepare(cfg.Security)
+		})
+		// Pick up certificates rotated on disk without a config change
+		go reloader.Watch(ctx, certs.DefaultWatchInterval)
+	}
+	watcher.OnReload("metrics", func(cfg *config.Config) (func(), error) {
+		interval, err := time.ParseDuration(cfg.Metrics.CollectionInterval)
+		if err != nil {
+			return nil, err
+		}
+		diskFilter, err := metrics.NewDiskFilter(cfg.Metrics)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			collector.SetInterval(interval)
+			collector.SetDiskFilter(diskFilter)
+			store.SetRetention(retention(cfg.Metrics.RetentionDays))
+		}, nil
+	})
+	watcher.OnReload("logging", func(cfg *config.Config) (func(), error) {
+		return func() { logging.SetLevel(cfg.Logging.Level) }, nil
+	})
+	go watcher.Watch(ctx, config.DefaultWatchInterval)
 
 	// Start server in a goroutine
+	errCh := make(chan error, 1)
 	go func() {
-		addr := fmt.Sprintf("%s:%d", cfg.HTTP.Host, cfg.HTTP.Port)
-		log.Printf("Starting server on %s", addr)
-		if err := app.Listen(addr); err != nil {
-			log.Fatalf("Failed to start server: %v", err)
-		}
+		errCh <- server.Start(ctx)
 	}()
 
-	// Graceful shutdown
+	// Run the background exporters alongside the server until ctx is done
+	var wg sync.WaitGroup
+	run := func(name string, start func(context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("%s stopped: %v", name, err)
+			}
+		}()
+	}
+	run("Metrics sampler", collector.Start)
+	run("Metrics history", func(ctx context.Context) error {
+		return store.Record(ctx, systemRegistry, collector.Interval)
+	})
+	if telemetry != nil {
+		run("Telemetry", telemetry.Start)
+	}
+	if remoteWriter != nil {
+		run("Remote write", remoteWriter.Start)
+	}
+	if otlpExporter != nil {
+		run("OTLP export", otlpExporter.Start)
+	}
+
+	// Graceful shutdown: stop every component when a signal arrives or the
+	// server fails
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+		log.Println("Shutting down server...")
+		cancel()
+		if err := <-errCh; err != nil {
+			log.Printf("Error during server shutdown: %v", err)
+		}
+	case err := <-errCh:
+		log.Printf("Failed to start server: %v", err)
+		cancel()
+	}
 
-	log.Println("Shutting down server...")
+	// Wait for the exporters to stop
+	wg.Wait()
 
 	// Unregister metrics collector
 	prometheus.Unregister(collector)
 
-	if err := app.Shutdown(); err != nil {
-		log.Printf("Error during server shutdown: %v", err)
-	}
+	// Stop any jobs that are still running
+	h.Close()
 
 	log.Println("Server gracefully stopped")
 }
 
-func setupRoutes(app *fiber.App, h *handlers.Handler) {
-	// Get the commands info
-	app.Get("/", h.Endpoints)
-
-	// Health check endpoint
-	app.Get("/alive", h.HealthCheck)
-
-	// Metrics endpoint
-	app.Get("/metrics", h.GetMetrics)
+// retention converts metrics.retention_days into a duration
+func retention(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
 
-	// IP endpoint
-	app.Get("/ip", h.GetIP)
+// printEffectiveConfig writes cfg to stdout as YAML with secrets redacted
+func printEffectiveConfig(cfg *config.Config) error {
+	redacted, err := cfg.Redacted()
+	if err != nil {
+		return err
+	}
+	data, err := redacted.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +16,26 @@ import (
 	"github.com/celestiaorg/talis-agent/internal/logging"
 )
 
+// ErrCircuitOpen is returned while the circuit breaker rejects requests
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// StatusError is returned when the server responds with an error status
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if it is sent again
+// later, i.e. the server failed or asked the client to slow down
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
 // CircuitBreakerState represents the state of the circuit breaker
 type CircuitBreakerState int
 
@@ -80,11 +101,30 @@ func NewClient(cfg ClientConfig) *Client {
 	}
 }
 
-// Request makes an HTTP request with circuit breaker, retries, and rate limiting
+// Request makes an HTTP request with circuit breaker, retries, and rate
+// limiting. body, if not nil, is sent as JSON.
 func (c *Client) Request(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return c.RawRequest(ctx, method, path, data, header)
+}
+
+// RawRequest is like Request but sends body as-is with the given headers.
+// The Authorization header is only set from the client token when header
+// does not already contain one.
+func (c *Client) RawRequest(ctx context.Context, method, path string, body []byte, header http.Header) ([]byte, error) {
 	// Check circuit breaker
 	if !c.breaker.AllowRequest() {
-		return nil, fmt.Errorf("circuit breaker is open")
+		return nil, ErrCircuitOpen
 	}
 
 	// Wait for rate limiter
@@ -102,7 +142,14 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 			}
 		}
 
-		resp, err := c.doRequest(ctx, method, path, body)
+		resp, err := c.doRequest(ctx, method, path, body, header)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.Temporary() {
+			// The server is reachable but rejected the request, which
+			// sending it again will not change
+			c.breaker.RecordSuccess()
+			return nil, err
+		}
 		if err != nil {
 			lastErr = err
 			c.breaker.RecordFailure()
@@ -123,14 +170,10 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 }
 
 // doRequest performs the actual HTTP request
-func (c *Client) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+func (c *Client) doRequest(ctx context.Context, method, path string, body []byte, header http.Header) ([]byte, error) {
 	var bodyReader io.Reader
 	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		bodyReader = bytes.NewReader(data)
+		bodyReader = bytes.NewReader(body)
 	}
 
 	url := fmt.Sprintf("%s%s", c.baseURL, path)
@@ -139,8 +182,12 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Authorization") == "" && c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -158,7 +205,7 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, respBody)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	return respBody, nil
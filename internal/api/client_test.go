@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -200,3 +201,77 @@ func TestRateLimiting(t *testing.T) {
 		t.Errorf("Requests completed too quickly. Expected > 2s, got %v", duration)
 	}
 }
+
+func TestStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:          server.URL,
+		RequestTimeout:   10 * time.Second,
+		RateLimit:        rate.Limit(10),
+		BurstLimit:       5,
+		FailureThreshold: 5,
+		ResetTimeout:     30 * time.Second,
+	})
+
+	_, err := client.Request(context.Background(), http.MethodGet, "/test", nil)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("Expected StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, statusErr.StatusCode)
+	}
+	if !statusErr.Temporary() {
+		t.Error("Expected 503 to be temporary")
+	}
+
+	for code, temporary := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusUnauthorized:        false,
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+	} {
+		if got := (&StatusError{StatusCode: code}).Temporary(); got != temporary {
+			t.Errorf("Expected Temporary() of %d to be %v, got %v", code, temporary, got)
+		}
+	}
+}
+
+func TestPermanentStatusErrorIsNotRetried(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{
+		BaseURL:          server.URL,
+		RequestTimeout:   10 * time.Second,
+		MaxRetries:       3,
+		RetryDelay:       time.Millisecond,
+		RateLimit:        rate.Limit(100),
+		BurstLimit:       10,
+		FailureThreshold: 2,
+		ResetTimeout:     30 * time.Second,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Request(context.Background(), http.MethodGet, "/test", nil)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("Expected StatusError, got %v", err)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests without retries, got %d", requests)
+	}
+	if !client.breaker.AllowRequest() {
+		t.Error("Expected rejected requests not to open the circuit breaker")
+	}
+}
@@ -0,0 +1,62 @@
+// Package atomicfile replaces files so that readers never see a partially
+// written file, even if the agent crashes while writing.
+package atomicfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// tempInfix separates the name of the replaced file from the random suffix
+// in the name of its temporary file
+const tempInfix = ".tmp-"
+
+// IsTemp reports whether name is the name of a temporary file created by
+// Write, which is only left behind if the agent crashed while writing
+func IsTemp(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, tempInfix)
+}
+
+// Write replaces path with the data written by write. The data is written
+// to a temporary file in the same directory, synced and renamed over path,
+// so path holds either its old or its new contents. The directory must
+// exist.
+func Write(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+tempInfix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		if rerr := os.Remove(tmpPath); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			logging.Error().Err(rerr).Str("path", tmpPath).Msg("failed to remove temporary file")
+		}
+		return err
+	}
+	return nil
+}
+
+// WriteFile replaces path with data, see Write
+func WriteFile(path string, data []byte) error {
+	return Write(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
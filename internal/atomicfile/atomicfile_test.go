@@ -0,0 +1,57 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	require.NoError(t, WriteFile(path, []byte("one")))
+	require.NoError(t, WriteFile(path, []byte("two")))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(data))
+}
+
+func TestWriteKeepsOldContentsOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	require.NoError(t, WriteFile(path, []byte("old")))
+
+	errWrite := errors.New("write failed")
+	err := Write(path, func(w io.Writer) error {
+		if _, err := w.Write([]byte("partial")); err != nil {
+			return err
+		}
+		return errWrite
+	})
+	require.ErrorIs(t, err, errWrite)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "old", string(data))
+
+	// The temporary file is removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestIsTemp(t *testing.T) {
+	dir := t.TempDir()
+	tmp, err := os.CreateTemp(dir, ".file"+tempInfix+"*")
+	require.NoError(t, err)
+	require.NoError(t, tmp.Close())
+
+	require.True(t, IsTemp(filepath.Base(tmp.Name())))
+	require.False(t, IsTemp("file"))
+	require.False(t, IsTemp("file.tmp-1"))
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/certs"
+	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+var (
+	// ErrMissingToken is returned when a request carries no bearer token
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken is returned when a request carries an unknown token
+	ErrInvalidToken = errors.New("invalid bearer token")
+	// ErrForbidden is returned when a token lacks the scope a route requires
+	ErrForbidden = errors.New("insufficient scope")
+)
+
+const (
+	// healthCheckPath is the path left public when PublicHealthCheck is set
+	healthCheckPath = "/alive"
+	// defaultTokenName is the identity name of the legacy auth_token
+	defaultTokenName = "default"
+	// anonymousName is the identity name used when authentication is disabled
+	anonymousName = "anonymous"
+
+	// clientCertPrefix prefixes identity names of client certificates
+	clientCertPrefix = "cert:"
+
+	// LocalsKey is the Fiber locals key holding the token name of a request
+	LocalsKey = "token"
+	// ClientCertLocalsKey is the Fiber locals key holding the subject of the
+	// verified client certificate of a request
+	ClientCertLocalsKey = "client_cert"
+	// identityLocalsKey is the Fiber locals key holding the request Identity
+	identityLocalsKey = "auth.identity"
+)
+
+// ErrorResponse is the JSON body returned for rejected requests
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// anonymousScopes are granted to unauthenticated requests when no credential
+// is configured and anonymous access was not explicitly allowed
+var anonymousScopes = []string{ScopeMetricsRead, ScopeIPRead}
+
+// credential is a configured token and the identity it authenticates
+type credential struct {
+	token    []byte
+	identity *Identity
+}
+
+// Authenticator validates bearer tokens on incoming requests and enforces
+// the scopes routes require
+type Authenticator struct {
+	state atomic.Pointer[credentialSet]
+}
+
+// credentialSet is the set of tokens and client certificates accepted by
+// an Authenticator
+type credentialSet struct {
+	credentials []credential
+	clientCerts map[string]*Identity
+	publicPaths map[string]bool
+	anonymous   *Identity
+}
+
+// New creates an Authenticator from the security configuration. The legacy
+// auth_token is granted every scope; named tokens and client certificates
+// only their listed scopes. Without any credential, requests are only granted
+// the read-only scopes unless cfg.AllowAnonymous is set.
+func New(cfg config.SecurityConfig) (*Authenticator, error) {
+	set, err := newCredentialSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{}
+	a.state.Store(set)
+	return a, nil
+}
+
+// Update replaces the accepted tokens and client certificates with those in
+// cfg. The current credentials are kept if cfg is invalid.
+func (a *Authenticator) Update(cfg config.SecurityConfig) error {
+	apply, err := a.Prepare(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Prepare builds the tokens and client certificates in cfg and returns a
+// function that switches to them. The current credentials are in use until
+// it is called.
+func (a *Authenticator) Prepare(cfg config.SecurityConfig) (func(), error) {
+	set, err := newCredentialSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func() { a.state.Store(set) }, nil
+}
+
+// newCredentialSet builds the credentials defined by cfg
+func newCredentialSet(cfg config.SecurityConfig) (*credentialSet, error) {
+	a := &credentialSet{
+		clientCerts: make(map[string]*Identity),
+		publicPaths: make(map[string]bool),
+	}
+	if cfg.PublicHealthCheck {
+		a.publicPaths[healthCheckPath] = true
+	}
+
+	token, err := cfg.ResolveAuthToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		if err := a.addCredential(defaultTokenName, token, []string{ScopeAll}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, tc := range cfg.Tokens {
+		if tc.Name == "" {
+			return nil, fmt.Errorf("token name must not be empty")
+		}
+		token, err := tc.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("token %q: %w", tc.Name, err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("token %q has no value", tc.Name)
+		}
+		if err := a.addCredential(tc.Name, token, tc.Scopes); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cc := range cfg.ClientCerts {
+		if cc.CommonName == "" {
+			return nil, fmt.Errorf("client certificate common name must not be empty")
+		}
+		if _, ok := a.clientCerts[cc.CommonName]; ok {
+			return nil, fmt.Errorf("duplicate client certificate common name %q", cc.CommonName)
+		}
+		identity, err := NewIdentity(clientCertPrefix+cc.CommonName, cc.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		a.clientCerts[cc.CommonName] = identity
+	}
+
+	if !a.enabled() {
+		if cfg.AllowAnonymous {
+			a.anonymous, _ = NewIdentity(anonymousName, []string{ScopeAll})
+			logging.Warn().Msg("No auth token configured, every API endpoint is unauthenticated")
+		} else {
+			a.anonymous, _ = NewIdentity(anonymousName, anonymousScopes)
+			logging.Warn().
+				Strs("scopes", anonymousScopes).
+				Msg("No auth token configured, unauthenticated requests are limited to read-only endpoints")
+		}
+	}
+
+	return a, nil
+}
+
+// addCredential registers a token, rejecting duplicate names and values
+func (a *credentialSet) addCredential(name, token string, scopes []string) error {
+	for _, c := range a.credentials {
+		if c.identity.Name == name {
+			return fmt.Errorf("duplicate token name %q", name)
+		}
+		if subtle.ConstantTimeCompare(c.token, []byte(token)) == 1 {
+			return fmt.Errorf("token %q reuses the value of token %q", name, c.identity.Name)
+		}
+	}
+
+	identity, err := NewIdentity(name, scopes)
+	if err != nil {
+		return err
+	}
+	a.credentials = append(a.credentials, credential{token: []byte(token), identity: identity})
+	return nil
+}
+
+// Enabled reports whether requests must carry a token
+func (a *Authenticator) Enabled() bool {
+	return a.state.Load().enabled()
+}
+
+// enabled reports whether the set contains any credential
+func (a *credentialSet) enabled() bool {
+	return len(a.credentials) > 0 || len(a.clientCerts) > 0
+}
+
+// Authenticate checks the Authorization header of a request to path, falling
+// back to the verified client certificate when no bearer token is sent. It
+// returns a nil Identity without error for public paths.
+func (a *Authenticator) Authenticate(path, authorization string, clientCert *x509.Certificate) (*Identity, error) {
+	return a.state.Load().authenticate(path, authorization, clientCert)
+}
+
+// authenticate implements Authenticate against the credentials in the set
+func (a *credentialSet) authenticate(path, authorization string, clientCert *x509.Certificate) (*Identity, error) {
+	if !a.enabled() {
+		return a.anonymous, nil
+	}
+
+	token, ok := bearerToken(authorization)
+	if !ok {
+		if clientCert != nil {
+			if identity, ok := a.clientCerts[clientCert.Subject.CommonName]; ok {
+				return identity, nil
+			}
+		}
+		if a.publicPaths[path] {
+			return nil, nil
+		}
+		return nil, ErrMissingToken
+	}
+
+	// Compare against every credential so timing does not reveal which matched
+	var identity *Identity
+	for _, c := range a.credentials {
+		if subtle.ConstantTimeCompare([]byte(token), c.token) == 1 {
+			identity = c.identity
+		}
+	}
+	if identity == nil {
+		if a.publicPaths[path] {
+			return nil, nil
+		}
+		return nil, ErrInvalidToken
+	}
+	return identity, nil
+}
+
+// Authorize checks that identity was granted scope
+func Authorize(identity *Identity, scope string) error {
+	if identity == nil {
+		return ErrMissingToken
+	}
+	if !identity.HasScope(scope) {
+		return fmt.Errorf("%w: token %q lacks %s", ErrForbidden, identity.Name, scope)
+	}
+	return nil
+}
+
+// Fiber returns middleware that authenticates requests to a Fiber app and
+// stores the caller identity in the request locals
+func (a *Authenticator) Fiber() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clientCert := certs.VerifiedLeaf(c.Context().TLSConnectionState())
+		if clientCert != nil {
+			c.Locals(ClientCertLocalsKey, clientCert.Subject.String())
+		}
+
+		identity, err := a.Authenticate(c.Path(), c.Get(fiber.HeaderAuthorization), clientCert)
+		if err != nil {
+			logging.Warn().Err(err).Str("path", c.Path()).Str("remote", c.IP()).Msg("Rejected request")
+			return fiberError(c, err)
+		}
+		if identity != nil {
+			c.Locals(identityLocalsKey, identity)
+			c.Locals(LocalsKey, identity.Name)
+		}
+		return c.Next()
+	}
+}
+
+// Require returns Fiber middleware that rejects callers without scope
+func Require(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		identity := FromFiber(c)
+		if err := Authorize(identity, scope); err != nil {
+			logging.Warn().Err(err).Str("path", c.Path()).Str("remote", c.IP()).Msg("Rejected request")
+			return fiberError(c, err)
+		}
+		return c.Next()
+	}
+}
+
+// FromFiber returns the identity stored by the Fiber middleware
+func FromFiber(c *fiber.Ctx) *Identity {
+	identity, _ := c.Locals(identityLocalsKey).(*Identity)
+	return identity
+}
+
+// ClientSubjectFromFiber returns the verified client certificate subject
+// stored by the Fiber middleware, or an empty string
+func ClientSubjectFromFiber(c *fiber.Ctx) string {
+	subject, _ := c.Locals(ClientCertLocalsKey).(string)
+	return subject
+}
+
+// statusFor maps an authentication error to an HTTP status and error code
+func statusFor(err error) (int, ErrorResponse) {
+	if errors.Is(err, ErrForbidden) {
+		return http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: err.Error()}
+	}
+	return http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: err.Error()}
+}
+
+// fiberError writes the JSON error response for err
+func fiberError(c *fiber.Ctx, err error) error {
+	status, body := statusFor(err)
+	if status == http.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="talis-agent"`)
+	}
+	return c.Status(status).JSON(body)
+}
+
+// bearerToken extracts the token from an Authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
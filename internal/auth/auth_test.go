@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/certs"
+	"github.com/celestiaorg/talis-agent/internal/certs/certstest"
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	a, err := New(config.SecurityConfig{AuthToken: "secret", PublicHealthCheck: true})
+	require.NoError(t, err)
+	require.True(t, a.Enabled())
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   error
+	}{
+		{name: "valid token", path: "/metrics", header: "Bearer secret"},
+		{name: "case insensitive scheme", path: "/metrics", header: "bearer secret"},
+		{name: "missing header", path: "/metrics", want: ErrMissingToken},
+		{name: "wrong scheme", path: "/metrics", header: "Basic c2VjcmV0", want: ErrMissingToken},
+		{name: "empty token", path: "/metrics", header: "Bearer ", want: ErrMissingToken},
+		{name: "wrong token", path: "/metrics", header: "Bearer nope", want: ErrInvalidToken},
+		{name: "public health check", path: "/alive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Authenticate(tt.path, tt.header, nil)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestAuthenticatePrivateHealthCheck(t *testing.T) {
+	a, err := New(config.SecurityConfig{AuthToken: "secret"})
+	require.NoError(t, err)
+	_, err = a.Authenticate("/alive", "", nil)
+	require.ErrorIs(t, err, ErrMissingToken)
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	a, err := New(config.SecurityConfig{})
+	require.NoError(t, err)
+	require.False(t, a.Enabled())
+
+	// Without credentials, anonymous requests may only read
+	identity, err := a.Authenticate("/commands", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, Authorize(identity, ScopeMetricsRead))
+	require.NoError(t, Authorize(identity, ScopeIPRead))
+	require.ErrorIs(t, Authorize(identity, ScopeCommandsExec), ErrForbidden)
+	require.ErrorIs(t, Authorize(identity, ScopePayloadWrite), ErrForbidden)
+	require.ErrorIs(t, Authorize(identity, ScopeJobsRead), ErrForbidden)
+
+	a, err = New(config.SecurityConfig{AllowAnonymous: true})
+	require.NoError(t, err)
+	identity, err = a.Authenticate("/commands", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, Authorize(identity, ScopeCommandsExec))
+}
+
+func TestNewTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	a, err := New(config.SecurityConfig{AuthTokenFile: path})
+	require.NoError(t, err)
+	identity, err := a.Authenticate("/ip", "Bearer from-file", nil)
+	require.NoError(t, err)
+	require.Equal(t, "default", identity.Name)
+
+	_, err = New(config.SecurityConfig{AuthTokenFile: filepath.Join(t.TempDir(), "missing")})
+	require.Error(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	a, err := New(config.SecurityConfig{AuthToken: "old"})
+	require.NoError(t, err)
+
+	require.NoError(t, a.Update(config.SecurityConfig{AuthToken: "new"}))
+	_, err = a.Authenticate("/ip", "Bearer old", nil)
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = a.Authenticate("/ip", "Bearer new", nil)
+	require.NoError(t, err)
+
+	// An invalid configuration keeps the current tokens
+	require.Error(t, a.Update(config.SecurityConfig{Tokens: []config.TokenConfig{{Name: "empty"}}}))
+	_, err = a.Authenticate("/ip", "Bearer new", nil)
+	require.NoError(t, err)
+
+	// Prepared credentials are only used once applied
+	apply, err := a.Prepare(config.SecurityConfig{AuthToken: "next"})
+	require.NoError(t, err)
+	_, err = a.Authenticate("/ip", "Bearer new", nil)
+	require.NoError(t, err)
+	apply()
+	_, err = a.Authenticate("/ip", "Bearer new", nil)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, a.Update(config.SecurityConfig{}))
+	require.False(t, a.Enabled())
+}
+
+func TestFiberMiddleware(t *testing.T) {
+	a, err := New(config.SecurityConfig{AuthToken: "secret"})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(a.Fiber())
+	app.Get("/ip", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/ip", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, `Bearer realm="talis-agent"`, resp.Header.Get("WWW-Authenticate"))
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "unauthorized", body.Error)
+	require.Equal(t, ErrMissingToken.Error(), body.Message)
+
+	req := httptest.NewRequest("GET", "/ip", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func scopedConfig() config.SecurityConfig {
+	return config.SecurityConfig{
+		Tokens: []config.TokenConfig{
+			{Name: "prometheus", Token: "scrape", Scopes: []string{ScopeMetricsRead}},
+			{Name: "orchestrator", Token: "deploy", Scopes: []string{ScopeCommandsExec, ScopeJobsRead}},
+		},
+	}
+}
+
+func TestScopedTokens(t *testing.T) {
+	a, err := New(scopedConfig())
+	require.NoError(t, err)
+	require.True(t, a.Enabled())
+
+	identity, err := a.Authenticate("/metrics", "Bearer scrape", nil)
+	require.NoError(t, err)
+	require.Equal(t, "prometheus", identity.Name)
+	require.NoError(t, Authorize(identity, ScopeMetricsRead))
+	require.ErrorIs(t, Authorize(identity, ScopeCommandsExec), ErrForbidden)
+
+	identity, err = a.Authenticate("/commands", "Bearer deploy", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{ScopeCommandsExec, ScopeJobsRead}, identity.Scopes())
+	require.ErrorIs(t, Authorize(identity, ScopePayloadWrite), ErrForbidden)
+}
+
+func TestNewRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []config.TokenConfig
+	}{
+		{name: "unknown scope", tokens: []config.TokenConfig{{Name: "a", Token: "x", Scopes: []string{"root"}}}},
+		{name: "missing name", tokens: []config.TokenConfig{{Token: "x"}}},
+		{name: "missing value", tokens: []config.TokenConfig{{Name: "a"}}},
+		{name: "duplicate name", tokens: []config.TokenConfig{{Name: "a", Token: "x"}, {Name: "a", Token: "y"}}},
+		{name: "duplicate value", tokens: []config.TokenConfig{{Name: "a", Token: "x"}, {Name: "b", Token: "x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.SecurityConfig{Tokens: tt.tokens})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFiberRequire(t *testing.T) {
+	a, err := New(scopedConfig())
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(a.Fiber())
+	app.Get("/metrics", Require(ScopeMetricsRead), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(LocalsKey).(string))
+	})
+	app.Post("/commands", Require(ScopeCommandsExec), func(c *fiber.Ctx) error {
+		return c.SendString("ran")
+	})
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req = httptest.NewRequest("POST", "/commands", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	var body ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "forbidden", body.Error)
+}
+
+func TestClientCertIdentity(t *testing.T) {
+	a, err := New(config.SecurityConfig{
+		AuthToken: "secret",
+		ClientCerts: []config.ClientCertConfig{
+			{CommonName: "orchestrator", Scopes: []string{ScopeCommandsExec}},
+		},
+	})
+	require.NoError(t, err)
+
+	mapped := &x509.Certificate{Subject: pkix.Name{CommonName: "orchestrator"}}
+	unmapped := &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}}
+
+	identity, err := a.Authenticate("/commands", "", mapped)
+	require.NoError(t, err)
+	require.Equal(t, "cert:orchestrator", identity.Name)
+	require.NoError(t, Authorize(identity, ScopeCommandsExec))
+	require.ErrorIs(t, Authorize(identity, ScopeMetricsRead), ErrForbidden)
+
+	_, err = a.Authenticate("/commands", "", unmapped)
+	require.ErrorIs(t, err, ErrMissingToken)
+
+	// A bearer token takes precedence over the client certificate
+	identity, err = a.Authenticate("/commands", "Bearer secret", mapped)
+	require.NoError(t, err)
+	require.Equal(t, "default", identity.Name)
+
+	_, err = New(config.SecurityConfig{ClientCerts: []config.ClientCertConfig{{CommonName: "a"}, {CommonName: "a"}}})
+	require.Error(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := certstest.NewCA(t, "talis-ca")
+	serverCert, serverKey := ca.IssueServer(t, "agent")
+	clientCert, clientKey := ca.IssueClient(t, "orchestrator")
+
+	sec := config.SecurityConfig{
+		TLSEnabled:   true,
+		CertFile:     certstest.WriteFile(t, dir, "server.pem", serverCert),
+		KeyFile:      certstest.WriteFile(t, dir, "server-key.pem", serverKey),
+		ClientCAFile: certstest.WriteFile(t, dir, "ca.pem", ca.PEM),
+		ClientAuth:   config.ClientAuthRequire,
+		ClientCerts: []config.ClientCertConfig{
+			{CommonName: "orchestrator", Scopes: []string{ScopeIPRead}},
+		},
+	}
+
+	reloader, err := certs.FromConfig(sec)
+	require.NoError(t, err)
+	a, err := New(sec)
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(a.Fiber())
+	app.Get("/ip", Require(ScopeIPRead), func(c *fiber.Ctx) error {
+		return c.SendString(FromFiber(c).Name + " " + ClientSubjectFromFiber(c))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.Listener(tls.NewListener(ln, reloader.TLSConfig())) }()
+	defer func() { _ = app.Shutdown() }()
+	serverURL := "https://" + ln.Addr().String()
+
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(ca.PEM))
+	keyPair, err := tls.X509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{keyPair},
+		MinVersion:   tls.VersionTLS12,
+	}}}
+	resp, err := client.Get(serverURL + "/ip")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "cert:orchestrator CN=orchestrator", string(body))
+
+	// Without a client certificate the handshake is rejected
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:    roots,
+		MinVersion: tls.VersionTLS12,
+	}}}
+	_, err = anonymous.Get(serverURL + "/ip")
+	require.Error(t, err)
+}
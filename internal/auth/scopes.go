@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+// Scopes that can be granted to API tokens, defined in config.Scopes
+const (
+	ScopeMetricsRead  = config.ScopeMetricsRead
+	ScopeIPRead       = config.ScopeIPRead
+	ScopePayloadWrite = config.ScopePayloadWrite
+	ScopeCommandsExec = config.ScopeCommandsExec
+	ScopeJobsRead     = config.ScopeJobsRead
+
+	// ScopeAll grants every scope
+	ScopeAll = config.ScopeAll
+)
+
+// knownScopes lists every scope a token may be granted
+var knownScopes = func() map[string]bool {
+	known := make(map[string]bool, len(config.Scopes))
+	for _, scope := range config.Scopes {
+		known[scope] = true
+	}
+	return known
+}()
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// Name identifies the token in logs
+	Name   string
+	scopes map[string]bool
+}
+
+// NewIdentity creates an Identity granted the given scopes
+func NewIdentity(name string, scopes []string) (*Identity, error) {
+	id := &Identity{
+		Name:   name,
+		scopes: make(map[string]bool, len(scopes)),
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return nil, fmt.Errorf("unknown scope %q for token %q", scope, name)
+		}
+		id.scopes[scope] = true
+	}
+	return id, nil
+}
+
+// HasScope reports whether the identity was granted scope
+func (i *Identity) HasScope(scope string) bool {
+	return i.scopes[ScopeAll] || i.scopes[scope]
+}
+
+// Scopes returns the granted scopes in sorted order
+func (i *Identity) Scopes() []string {
+	scopes := make([]string, 0, len(i.scopes))
+	for scope := range i.scopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// String implements fmt.Stringer
+func (i *Identity) String() string {
+	return fmt.Sprintf("%s[%s]", i.Name, strings.Join(i.Scopes(), ","))
+}
@@ -0,0 +1,291 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// DefaultWatchInterval is how often the certificate files are checked for changes
+const DefaultWatchInterval = 10 * time.Second
+
+// Reloader serves a TLS certificate that can be replaced on disk while the
+// server is running. New handshakes pick up the reloaded certificate while
+// established connections are left untouched.
+type Reloader struct {
+	clientAuth tls.ClientAuthType
+
+	// reload serializes Reload with applying prepared certificates so a
+	// reload of the old files cannot overwrite newly applied ones
+	reload sync.Mutex
+
+	mu        sync.RWMutex
+	files     files
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	version   fileVersion
+}
+
+// files are the paths the certificates are loaded from
+type files struct {
+	cert     string
+	key      string
+	clientCA string
+}
+
+// fileVersion identifies the state of the certificate files on disk
+type fileVersion struct {
+	certMod  time.Time
+	certSize int64
+	keyMod   time.Time
+	keySize  int64
+	caMod    time.Time
+	caSize   int64
+}
+
+// ClientAuthType maps a config.SecurityConfig client auth mode to the
+// crypto/tls verification policy
+func ClientAuthType(mode string) tls.ClientAuthType {
+	switch mode {
+	case config.ClientAuthRequest:
+		return tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// FromConfig creates a Reloader for the server certificate and client
+// certificate settings in cfg
+func FromConfig(cfg config.SecurityConfig) (*Reloader, error) {
+	r := &Reloader{
+		clientAuth: ClientAuthType(cfg.ClientAuth),
+	}
+	if err := r.Update(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Update switches to the certificate files in cfg and loads them. The
+// current certificate and files are kept if the new ones cannot be loaded.
+// The client authentication mode is fixed when the Reloader is created.
+func (r *Reloader) Update(cfg config.SecurityConfig) error {
+	apply, err := r.Prepare(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Prepare loads the certificate files in cfg and returns a function that
+// switches to them. The current certificate is served until it is called.
+func (r *Reloader) Prepare(cfg config.SecurityConfig) (func(), error) {
+	f := files{cert: cfg.CertFile, key: cfg.KeyFile}
+	if r.clientAuth != tls.NoClientCert {
+		f.clientCA = cfg.ClientCAFile
+	}
+	l, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		r.reload.Lock()
+		defer r.reload.Unlock()
+		r.store(l)
+	}, nil
+}
+
+// NewReloader loads the key pair and returns a Reloader serving it
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{}
+	if err := r.load(files{cert: certFile, key: keyFile}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the key pair from disk. The current certificate is kept if
+// the new one cannot be loaded.
+func (r *Reloader) Reload() error {
+	r.reload.Lock()
+	defer r.reload.Unlock()
+
+	r.mu.RLock()
+	f := r.files
+	r.mu.RUnlock()
+	return r.load(f)
+}
+
+// load reads the certificates from f and serves them
+func (r *Reloader) load(f files) error {
+	l, err := f.load()
+	if err != nil {
+		return err
+	}
+	r.store(l)
+	return nil
+}
+
+// loaded are the certificates read from a set of files
+type loaded struct {
+	files     files
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	version   fileVersion
+}
+
+// load reads the certificates from f
+func (f files) load() (*loaded, error) {
+	version, err := f.stat()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.cert, f.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if f.clientCA != "" {
+		data, err := os.ReadFile(f.clientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("client CA file contains no certificates: %s", f.clientCA)
+		}
+	}
+
+	return &loaded{files: f, cert: &cert, clientCAs: clientCAs, version: version}, nil
+}
+
+// store serves the certificates in l
+func (r *Reloader) store(l *loaded) {
+	r.mu.Lock()
+	r.files = l.files
+	r.cert = l.cert
+	r.clientCAs = l.clientCAs
+	r.version = l.version
+	r.mu.Unlock()
+
+	logging.Info().Str("cert_file", l.files.cert).Msg("TLS certificate loaded")
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server TLS configuration backed by the Reloader
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+	if r.clientAuth != tls.NoClientCert {
+		// Resolve the client CA pool per handshake so reloads take effect
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: r.GetCertificate,
+				ClientAuth:     r.clientAuth,
+				ClientCAs:      r.clientCAs,
+			}, nil
+		}
+	}
+	return cfg
+}
+
+// VerifiedLeaf returns the verified client certificate of a connection, or
+// nil if the client did not present one that chains to the client CAs
+func VerifiedLeaf(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// Watch reloads the certificate whenever the files change on disk, checking
+// every interval, until ctx is done. SIGHUP is handled by config.Watcher,
+// which reloads the certificate through Prepare.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				logging.Error().Err(err).Msg("Failed to reload TLS certificate, keeping the current one")
+			}
+		}
+	}
+}
+
+// changed reports whether the files on disk differ from the loaded ones
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	f, current := r.files, r.version
+	r.mu.RUnlock()
+
+	version, err := f.stat()
+	if err != nil {
+		return false
+	}
+	return version != current
+}
+
+// stat returns the current version of the certificate files
+func (f files) stat() (fileVersion, error) {
+	certInfo, err := os.Stat(f.cert)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to stat certificate file: %w", err)
+	}
+	keyInfo, err := os.Stat(f.key)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to stat key file: %w", err)
+	}
+
+	version := fileVersion{
+		certMod:  certInfo.ModTime(),
+		certSize: certInfo.Size(),
+		keyMod:   keyInfo.ModTime(),
+		keySize:  keyInfo.Size(),
+	}
+
+	if f.clientCA != "" {
+		caInfo, err := os.Stat(f.clientCA)
+		if err != nil {
+			return fileVersion{}, fmt.Errorf("failed to stat client CA file: %w", err)
+		}
+		version.caMod = caInfo.ModTime()
+		version.caSize = caInfo.Size()
+	}
+
+	return version, nil
+}
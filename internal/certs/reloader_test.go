@@ -0,0 +1,105 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/certs/certstest"
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+// writeKeyPair writes a certificate for commonName to cert.pem and key.pem
+// in dir
+func writeKeyPair(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	certPEM, keyPEM := certstest.NewCA(t, "talis-ca").IssueServer(t, commonName)
+	return certstest.WriteFile(t, dir, "cert.pem", certPEM), certstest.WriteFile(t, dir, "key.pem", keyPEM)
+}
+
+// commonName returns the subject CN of the certificate served by r
+func commonName(t *testing.T, r *Reloader) string {
+	t.Helper()
+
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	require.Equal(t, "first", commonName(t, r))
+	require.False(t, r.changed())
+
+	writeKeyPair(t, dir, "second")
+	require.NoError(t, r.Reload())
+	require.Equal(t, "second", commonName(t, r))
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0600))
+	require.Error(t, r.Reload())
+	require.Equal(t, "first", commonName(t, r))
+}
+
+func TestUpdateSwitchesFiles(t *testing.T) {
+	certFile, keyFile := writeKeyPair(t, t.TempDir(), "first")
+	otherCert, otherKey := writeKeyPair(t, t.TempDir(), "second")
+
+	r, err := FromConfig(config.SecurityConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	require.NoError(t, r.Update(config.SecurityConfig{CertFile: otherCert, KeyFile: otherKey}))
+	require.Equal(t, "second", commonName(t, r))
+
+	// Missing files keep the current certificate and paths
+	require.Error(t, r.Update(config.SecurityConfig{CertFile: certFile, KeyFile: filepath.Join(t.TempDir(), "missing.pem")}))
+	require.Equal(t, "second", commonName(t, r))
+	require.NoError(t, r.Reload())
+	require.Equal(t, "second", commonName(t, r))
+}
+
+func TestNewReloaderMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	require.Error(t, err)
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "first")
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 10*time.Millisecond)
+
+	// Ensure the modification time differs on filesystems with coarse timestamps
+	future := time.Now().Add(time.Minute)
+	writeKeyPair(t, dir, "rotated")
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	require.Eventually(t, func() bool {
+		return commonName(t, r) == "rotated"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout is used when a Spec does not set a timeout
+	DefaultTimeout = time.Minute
+	// MaxOutputBytes is the maximum amount of stdout and stderr kept per command
+	MaxOutputBytes = 1 << 20
+)
+
+// ErrInvalidSpec is returned when a Spec cannot be executed
+var ErrInvalidSpec = errors.New("invalid command spec")
+
+// Spec describes a command to execute
+type Spec struct {
+	// Argv is executed directly, without a shell
+	Argv []string `json:"argv,omitempty"`
+	// Script is executed with bash -c
+	Script string `json:"script,omitempty"`
+	// Dir is the working directory of the command
+	Dir string `json:"dir,omitempty"`
+	// Env holds extra environment variables added to the agent's environment
+	Env map[string]string `json:"env,omitempty"`
+	// Timeout is a duration string such as "30s"
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks that the spec can be executed
+func (s *Spec) Validate() error {
+	if len(s.Argv) == 0 && s.Script == "" {
+		return fmt.Errorf("%w: one of argv or script is required", ErrInvalidSpec)
+	}
+	if len(s.Argv) > 0 && s.Script != "" {
+		return fmt.Errorf("%w: argv and script are mutually exclusive", ErrInvalidSpec)
+	}
+	if len(s.Argv) > 0 && s.Argv[0] == "" {
+		return fmt.Errorf("%w: argv[0] must not be empty", ErrInvalidSpec)
+	}
+	if _, err := s.TimeoutDuration(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TimeoutDuration returns the parsed timeout, or DefaultTimeout if unset
+func (s *Spec) TimeoutDuration() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("%w: invalid timeout: %s", ErrInvalidSpec, s.Timeout)
+	}
+	return timeout, nil
+}
+
+// Result represents the outcome of a command execution
+type Result struct {
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+	DurationMs      int64  `json:"duration_ms"`
+}
+
+// Success reports whether the command exited with status zero
+func (r *Result) Success() bool {
+	return r.ExitCode == 0 && !r.TimedOut
+}
+
+// Executor runs commands
+type Executor interface {
+	// Execute runs spec until it exits or ctx is done, writing its output
+	// to stdout and stderr. It returns the exit code of the process, or an
+	// error if the process could not be started.
+	Execute(ctx context.Context, spec Spec, stdout, stderr io.Writer) (int, error)
+}
+
+// Run validates spec, executes it with the spec timeout and collects the
+// output into a Result
+func Run(ctx context.Context, executor Executor, spec Spec) (*Result, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout, _ := spec.TimeoutDuration()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stdout := NewOutputBuffer(MaxOutputBytes)
+	stderr := NewOutputBuffer(MaxOutputBytes)
+
+	start := time.Now()
+	exitCode, err := executor.Execute(ctx, spec, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ExitCode:        exitCode,
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		TimedOut:        errors.Is(ctx.Err(), context.DeadlineExceeded),
+		DurationMs:      time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// OutputBuffer is a concurrency-safe buffer that silently drops writes
+// once limit bytes have been stored
+type OutputBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// NewOutputBuffer creates an OutputBuffer holding at most limit bytes
+func NewOutputBuffer(limit int) *OutputBuffer {
+	return &OutputBuffer{
+		limit: limit,
+	}
+}
+
+// Write implements io.Writer
+func (b *OutputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the buffered output
+func (b *OutputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Truncated reports whether any output was dropped
+func (b *OutputBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
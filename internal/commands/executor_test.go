@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr bool
+	}{
+		{name: "argv", spec: Spec{Argv: []string{"echo", "hi"}}},
+		{name: "script", spec: Spec{Script: "echo hi", Timeout: "5s"}},
+		{name: "empty", spec: Spec{}, wantErr: true},
+		{name: "both", spec: Spec{Argv: []string{"echo"}, Script: "echo"}, wantErr: true},
+		{name: "empty argv0", spec: Spec{Argv: []string{""}}, wantErr: true},
+		{name: "bad timeout", spec: Spec{Script: "true", Timeout: "soon"}, wantErr: true},
+		{name: "negative timeout", spec: Spec{Script: "true", Timeout: "-1s"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidSpec)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunOSExecutor(t *testing.T) {
+	executor := NewOSExecutor()
+	dir := t.TempDir()
+
+	result, err := Run(context.Background(), executor, Spec{
+		Script: `echo "$GREETING from $(pwd)"; echo oops >&2; exit 3`,
+		Dir:    dir,
+		Env:    map[string]string{"GREETING": "hello"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.ExitCode)
+	require.Equal(t, "hello from "+dir+"\n", result.Stdout)
+	require.Equal(t, "oops\n", result.Stderr)
+	require.False(t, result.Success())
+
+	result, err = Run(context.Background(), executor, Spec{Argv: []string{"echo", "-n", "argv"}})
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "argv", result.Stdout)
+	require.True(t, result.Success())
+}
+
+func TestRunTimeout(t *testing.T) {
+	result, err := Run(context.Background(), NewOSExecutor(), Spec{
+		Script:  "sleep 10",
+		Timeout: "100ms",
+	})
+	require.NoError(t, err)
+	require.True(t, result.TimedOut)
+	require.False(t, result.Success())
+	require.Less(t, result.DurationMs, int64(5000))
+}
+
+func TestRunStartError(t *testing.T) {
+	_, err := Run(context.Background(), NewOSExecutor(), Spec{
+		Argv: []string{"/nonexistent/binary"},
+	})
+	require.Error(t, err)
+}
+
+func TestRunFakeExecutor(t *testing.T) {
+	fake := &FakeExecutor{Stdout: "out", Stderr: "err", ExitCode: 1}
+	spec := Spec{Argv: []string{"celestia-appd", "init"}}
+
+	result, err := Run(context.Background(), fake, spec)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ExitCode)
+	require.Equal(t, "out", result.Stdout)
+	require.Equal(t, "err", result.Stderr)
+	require.Equal(t, []Spec{spec}, fake.Specs())
+
+	fake = &FakeExecutor{Err: errors.New("boom")}
+	_, err = Run(context.Background(), fake, spec)
+	require.Error(t, err)
+}
+
+func TestOutputBuffer(t *testing.T) {
+	buf := NewOutputBuffer(4)
+
+	n, err := buf.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.False(t, buf.Truncated())
+
+	n, err = buf.Write([]byte("def"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.True(t, buf.Truncated())
+	require.Equal(t, "abcd", buf.String())
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// FakeExecutor is an Executor for tests that records specs and returns
+// canned output instead of running anything
+type FakeExecutor struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Err      error
+	// Block makes Execute wait until ctx is done before returning
+	Block bool
+
+	mu    sync.Mutex
+	specs []Spec
+}
+
+// Execute implements Executor
+func (f *FakeExecutor) Execute(ctx context.Context, spec Spec, stdout, stderr io.Writer) (int, error) {
+	f.mu.Lock()
+	f.specs = append(f.specs, spec)
+	f.mu.Unlock()
+
+	if f.Err != nil {
+		return -1, f.Err
+	}
+
+	if _, err := io.WriteString(stdout, f.Stdout); err != nil {
+		return -1, err
+	}
+	if _, err := io.WriteString(stderr, f.Stderr); err != nil {
+		return -1, err
+	}
+
+	if f.Block {
+		<-ctx.Done()
+		return -1, nil
+	}
+
+	return f.ExitCode, nil
+}
+
+// Specs returns the specs Execute has been called with
+func (f *FakeExecutor) Specs() []Spec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Spec(nil), f.specs...)
+}
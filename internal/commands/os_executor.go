@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// waitDelay bounds how long Wait blocks on output pipes after the process
+// has been killed, e.g. when a background child keeps them open
+const waitDelay = 5 * time.Second
+
+// OSExecutor runs commands on the host using os/exec
+type OSExecutor struct {
+	// Shell is the shell used to run scripts
+	Shell string
+}
+
+// NewOSExecutor creates a new OSExecutor that runs scripts with bash
+func NewOSExecutor() *OSExecutor {
+	return &OSExecutor{
+		Shell: "/bin/bash",
+	}
+}
+
+// Execute implements Executor
+func (e *OSExecutor) Execute(ctx context.Context, spec Spec, stdout, stderr io.Writer) (int, error) {
+	var cmd *exec.Cmd
+	if spec.Script != "" {
+		cmd = exec.CommandContext(ctx, e.Shell, "-c", spec.Script) // nolint: gosec
+	} else {
+		cmd = exec.CommandContext(ctx, spec.Argv[0], spec.Argv[1:]...) // nolint: gosec
+	}
+
+	cmd.Dir = spec.Dir
+	cmd.Env = buildEnv(spec.Env)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	configureProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		if ctx.Err() != nil {
+			return -1, nil
+		}
+		return -1, err
+	}
+
+	return 0, nil
+}
+
+// buildEnv appends extra variables to the agent's environment in a stable order
+func buildEnv(extra map[string]string) []string {
+	env := os.Environ()
+
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		env = append(env, k+"="+extra[k])
+	}
+	return env
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package commands
+
+import "os/exec"
+
+// configureProcessGroup is a no-op on platforms without process groups
+func configureProcessGroup(*exec.Cmd) {}
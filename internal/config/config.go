@@ -1,14 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
+// configPaths are searched in order and the first existing file is loaded
 var configPaths = []string{
 	"/etc/talis-agent/config.yaml",
 	"config.yaml",
@@ -21,35 +25,206 @@ func SetConfigPaths(paths []string) {
 
 // Config represents the application configuration
 type Config struct {
-	HTTP     HTTPConfig     `yaml:"http"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Metrics  MetricsConfig  `yaml:"metrics"`
-	Security SecurityConfig `yaml:"security"`
+	HTTP     HTTPConfig     `yaml:"http" doc:"HTTP server"`
+	Logging  LoggingConfig  `yaml:"logging" doc:"Logging"`
+	Metrics  MetricsConfig  `yaml:"metrics" doc:"System metrics collection"`
+	Security SecurityConfig `yaml:"security" doc:"TLS and authentication"`
+	API      APIConfig      `yaml:"api" doc:"Talis API the agent checks in with and pushes telemetry to"`
+	// RemoteWrite pushes metrics for agents that cannot be scraped
+	RemoteWrite RemoteWriteConfig `yaml:"remote_write" doc:"Push metrics to a Prometheus remote write endpoint"`
+	OTLP        OTLPConfig        `yaml:"otlp" doc:"Export metrics to an OpenTelemetry collector over OTLP/HTTP"`
 }
 
 // HTTPConfig contains HTTP server configuration
 type HTTPConfig struct {
-	Port int    `yaml:"port"`
-	Host string `yaml:"host"`
+	Port int    `yaml:"port" doc:"Port to listen on" min:"1" max:"65535"`
+	Host string `yaml:"host" doc:"Address to listen on; leave empty to listen on every interface"`
 }
 
 // LoggingConfig contains logging configuration
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
+	Level  string `yaml:"level" doc:"Minimum log level" enum:"debug,info,warn,error"`
+	Format string `yaml:"format" doc:"Log output format" enum:"json,text"`
 }
 
 // MetricsConfig contains metrics collection configuration
 type MetricsConfig struct {
-	CollectionInterval string `yaml:"collection_interval"`
-	RetentionDays      int    `yaml:"retention_days"`
+	CollectionInterval string `yaml:"collection_interval" doc:"How often system metrics are collected, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	RetentionDays      int    `yaml:"retention_days" doc:"Number of days collected metrics are kept" min:"1"`
+	// HistoryFile persists the metrics history served on /metrics/history
+	HistoryFile string `yaml:"history_file" doc:"File the metrics history is saved to so it survives restarts; leave empty to keep it in memory only"`
+	// Disk filters select the filesystems reported by the system_disk_*
+	// metrics. Patterns are regular expressions matching the whole value.
+	DiskIncludeMountpoints []string `yaml:"disk_include_mountpoints" doc:"Regular expressions of mountpoints to report; empty reports every mountpoint" example:"[\"/\", \"/mnt/celestia.*\"]"`
+	DiskExcludeMountpoints []string `yaml:"disk_exclude_mountpoints" doc:"Regular expressions of mountpoints not to report" example:"[\"/boot.*\", \"/snap/.*\"]"`
+	DiskIncludeFSTypes     []string `yaml:"disk_include_fstypes" doc:"Regular expressions of filesystem types to report; empty reports every type except pseudo filesystems such as proc, sysfs and overlay" example:"[ext4, xfs]"`
+	DiskExcludeFSTypes     []string `yaml:"disk_exclude_fstypes" doc:"Regular expressions of filesystem types not to report" example:"[tmpfs]"`
 }
 
 // SecurityConfig contains security-related configuration
 type SecurityConfig struct {
-	TLSEnabled bool   `yaml:"tls_enabled"`
-	CertFile   string `yaml:"cert_file"`
-	KeyFile    string `yaml:"key_file"`
+	TLSEnabled bool   `yaml:"tls_enabled" doc:"Serve HTTPS using cert_file and key_file"`
+	CertFile   string `yaml:"cert_file" doc:"Path to the PEM encoded TLS certificate"`
+	KeyFile    string `yaml:"key_file" doc:"Path to the PEM encoded TLS private key"`
+	// AuthToken is the bearer token required on every request. Leaving both
+	// AuthToken and AuthTokenFile empty disables authentication.
+	AuthToken     string `yaml:"auth_token" secret:"true" doc:"Bearer token granted every scope; leave empty with auth_token_file to disable authentication"`
+	AuthTokenFile string `yaml:"auth_token_file" doc:"File to read the bearer token from instead of auth_token"`
+	// AllowAnonymous grants every scope to unauthenticated requests while no
+	// credential is configured. Without it they only get the read scopes.
+	AllowAnonymous bool `yaml:"allow_anonymous" doc:"Grant every scope, including commands:exec and payload:write, to requests without credentials while no token or client certificate is configured; otherwise they may only read metrics and the host IP"`
+	// PublicHealthCheck leaves /alive reachable without a token
+	PublicHealthCheck bool `yaml:"public_health_check" doc:"Allow /alive without a token"`
+	// Tokens are named tokens limited to a set of scopes
+	Tokens []TokenConfig `yaml:"tokens" doc:"Named tokens limited to a set of scopes" example:"- name: prometheus\n  token_file: /etc/talis-agent/prometheus.token\n  scopes: [metrics:read]"`
+	// ClientCAFile is the PEM bundle used to verify client certificates
+	ClientCAFile string `yaml:"client_ca_file" doc:"PEM bundle used to verify client certificates"`
+	// ClientAuth is the client certificate mode: none, request or require
+	ClientAuth string `yaml:"client_auth" doc:"Client certificate mode" enum:"none,request,require"`
+	// ClientCerts grant scopes to verified client certificates by common name
+	ClientCerts []ClientCertConfig `yaml:"client_certs" doc:"Scopes granted to verified client certificates by common name" example:"- common_name: talis-orchestrator\n  scopes: [ip:read, payload:write, commands:exec, jobs:read]"`
+}
+
+// Client certificate verification modes
+const (
+	// ClientAuthNone does not ask for client certificates
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies a client certificate if one is presented
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects connections without a valid client certificate
+	ClientAuthRequire = "require"
+)
+
+// Scopes that can be granted to API tokens and client certificates
+const (
+	ScopeMetricsRead  = "metrics:read"
+	ScopeIPRead       = "ip:read"
+	ScopePayloadWrite = "payload:write"
+	ScopeCommandsExec = "commands:exec"
+	ScopeJobsRead     = "jobs:read"
+
+	// ScopeAll grants every scope
+	ScopeAll = "*"
+)
+
+// Scopes lists every scope that can be granted
+var Scopes = []string{ScopeMetricsRead, ScopeIPRead, ScopePayloadWrite, ScopeCommandsExec, ScopeJobsRead, ScopeAll}
+
+// ClientCertConfig grants scopes to a client certificate common name
+type ClientCertConfig struct {
+	CommonName string   `yaml:"common_name" doc:"Subject common name of the client certificate"`
+	Scopes     []string `yaml:"scopes" doc:"Scopes granted to the certificate" enum:"@scopes"`
+}
+
+// TokenConfig describes a named API token and the scopes it grants
+type TokenConfig struct {
+	Name      string   `yaml:"name" doc:"Token name recorded in the request log"`
+	Token     string   `yaml:"token" secret:"true" doc:"Token value"`
+	TokenFile string   `yaml:"token_file" doc:"File to read the token value from instead of token"`
+	Scopes    []string `yaml:"scopes" doc:"Scopes granted to the token" enum:"@scopes"`
+}
+
+// Resolve returns the token value, reading it from TokenFile when Token is not set
+func (t *TokenConfig) Resolve() (string, error) {
+	return readToken("token_file", t.Token, t.TokenFile)
+}
+
+// ResolveAuthToken returns the configured bearer token, reading it from
+// AuthTokenFile when AuthToken is not set
+func (s *SecurityConfig) ResolveAuthToken() (string, error) {
+	return readToken("security.auth_token_file", s.AuthToken, s.AuthTokenFile)
+}
+
+// APIConfig contains the settings of the Talis API client. Telemetry is
+// disabled when BaseURL is empty.
+type APIConfig struct {
+	BaseURL   string `yaml:"base_url" doc:"Base URL of the Talis API; leave empty to disable check-ins and telemetry" example:"https://talis.example.com/api/v1"`
+	Token     string `yaml:"token" secret:"true" doc:"Bearer token sent to the API"`
+	TokenFile string `yaml:"token_file" doc:"File to read the API token from instead of token"`
+	// RequestTimeout bounds a single attempt of a request
+	RequestTimeout string  `yaml:"request_timeout" doc:"Timeout of a single API request, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	MaxRetries     int     `yaml:"max_retries" doc:"Number of times a failed request is retried" min:"0"`
+	RetryDelay     string  `yaml:"retry_delay" doc:"Delay between retries of a failed request, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	RateLimit      float64 `yaml:"rate_limit" doc:"Maximum number of requests per second"`
+	BurstLimit     int     `yaml:"burst_limit" doc:"Number of requests allowed to exceed rate_limit in a burst" min:"1"`
+	// FailureThreshold failed requests in a row open the circuit breaker,
+	// which rejects requests until ResetTimeout has passed
+	FailureThreshold int    `yaml:"failure_threshold" doc:"Consecutive failures that open the circuit breaker" min:"1"`
+	ResetTimeout     string `yaml:"reset_timeout" doc:"How long the open circuit breaker rejects requests, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	CheckinInterval  string `yaml:"checkin_interval" doc:"How often the agent checks in with the API, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	// QueueDir buffers check-ins and metric batches while the API cannot be
+	// reached. Entries older than metrics.retention_days are dropped.
+	QueueDir       string `yaml:"queue_dir" doc:"Directory unsent check-ins and metric batches are kept in until the API is reachable; leave empty to drop them"`
+	QueueMaxSizeMB int    `yaml:"queue_max_size_mb" doc:"Maximum size of the queue in MiB; the oldest entries are dropped first" min:"1"`
+}
+
+// ResolveToken returns the API token, reading it from TokenFile when Token is not set
+func (a *APIConfig) ResolveToken() (string, error) {
+	return readToken("api.token_file", a.Token, a.TokenFile)
+}
+
+// RemoteWriteConfig contains the settings of the Prometheus remote write
+// exporter. The exporter is disabled when URL is empty. Requests are retried
+// with the retry and circuit breaker settings of the api section.
+type RemoteWriteConfig struct {
+	URL      string `yaml:"url" doc:"Remote write endpoint; leave empty to disable remote write" example:"https://prometheus.example.com/api/v1/write"`
+	Interval string `yaml:"interval" doc:"How often metrics are pushed, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	Timeout  string `yaml:"timeout" doc:"Timeout of a single push, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	// Username and Password enable basic auth; BearerToken is sent instead
+	// when set
+	Username        string `yaml:"username" doc:"Basic auth username"`
+	Password        string `yaml:"password" secret:"true" doc:"Basic auth password"`
+	PasswordFile    string `yaml:"password_file" doc:"File to read the basic auth password from instead of password"`
+	BearerToken     string `yaml:"bearer_token" secret:"true" doc:"Bearer token sent instead of basic auth"`
+	BearerTokenFile string `yaml:"bearer_token_file" doc:"File to read the bearer token from instead of bearer_token"`
+	// ExternalLabels are added to every series that does not already have
+	// a label of the same name
+	ExternalLabels map[string]string `yaml:"external_labels" doc:"Labels added to every pushed series" example:"network: mocha-4\nrole: validator\nregion: eu-west"`
+}
+
+// ResolvePassword returns the basic auth password, reading it from
+// PasswordFile when Password is not set
+func (r *RemoteWriteConfig) ResolvePassword() (string, error) {
+	return readToken("remote_write.password_file", r.Password, r.PasswordFile)
+}
+
+// ResolveBearerToken returns the bearer token, reading it from
+// BearerTokenFile when BearerToken is not set
+func (r *RemoteWriteConfig) ResolveBearerToken() (string, error) {
+	return readToken("remote_write.bearer_token_file", r.BearerToken, r.BearerTokenFile)
+}
+
+// OTLPConfig contains the settings of the OpenTelemetry metrics exporter.
+// The exporter is disabled when Endpoint is empty.
+type OTLPConfig struct {
+	Endpoint string `yaml:"endpoint" doc:"OTLP/HTTP metrics endpoint; leave empty to disable OTLP export" example:"http://otel-collector:4318/v1/metrics"`
+	Interval string `yaml:"interval" doc:"How often metrics are exported, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	Timeout  string `yaml:"timeout" doc:"Timeout of a single export, as a Go duration" pattern:"^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"`
+	// Headers are sent with every export, typically for authentication
+	Headers map[string]string `yaml:"headers" secret:"true" doc:"HTTP headers sent with every export" example:"Authorization: Bearer change-me"`
+	// ResourceAttributes are added to the host.name, os.type and os.name
+	// attributes derived from the host
+	ResourceAttributes map[string]string `yaml:"resource_attributes" doc:"Extra resource attributes of the exported metrics" example:"deployment.environment: testnet\nnetwork: mocha-4"`
+}
+
+// readToken returns token, or the trimmed contents of path when token is
+// empty. Errors are *FieldError values naming field, the setting path was
+// read from.
+func readToken(field, token, path string) (string, error) {
+	if token != "" || path == "" {
+		return token, nil
+	}
+
+	data, err := os.ReadFile(path) // nolint: gosec
+	if err != nil {
+		return "", &FieldError{Path: field, Message: fmt.Sprintf("failed to read file: %v", err)}
+	}
+
+	token = strings.TrimSpace(string(data))
+	if token == "" {
+		return "", &FieldError{Path: field, Message: "file is empty: " + path}
+	}
+	return token, nil
 }
 
 // DefaultConfig returns the default configuration
@@ -66,46 +241,95 @@ func DefaultConfig() *Config {
 		Metrics: MetricsConfig{
 			CollectionInterval: "15s",
 			RetentionDays:      7,
+			HistoryFile:        "/var/lib/talis-agent/history.gob",
 		},
 		Security: SecurityConfig{
-			TLSEnabled: false,
+			TLSEnabled:        false,
+			PublicHealthCheck: true,
+			ClientAuth:        ClientAuthNone,
+		},
+		API: APIConfig{
+			RequestTimeout:   "10s",
+			MaxRetries:       3,
+			RetryDelay:       "1s",
+			RateLimit:        20,
+			BurstLimit:       5,
+			FailureThreshold: 5,
+			ResetTimeout:     "30s",
+			CheckinInterval:  "1m",
+			QueueDir:         "/var/lib/talis-agent/queue",
+			QueueMaxSizeMB:   64,
+		},
+		RemoteWrite: RemoteWriteConfig{
+			Interval: "30s",
+			Timeout:  "10s",
+		},
+		OTLP: OTLPConfig{
+			Interval: "30s",
+			Timeout:  "10s",
 		},
 	}
 }
 
-// Load loads the configuration from the specified file
+// Load loads the configuration from the first existing file in the search
+// paths, falling back to DefaultConfig when none exists
 func Load() (*Config, error) {
-	var configFile string
 	for _, path := range configPaths {
 		if _, err := os.Stat(path); err == nil {
-			configFile = path
-			break
+			return LoadFile(path)
 		}
 	}
 
-	cfg := DefaultConfig()
-
-	if configFile == "" {
-		return cfg, nil
-	}
+	return DefaultConfig(), nil
+}
 
-	data, err := os.ReadFile(configFile) // nolint: gosec
+// LoadFile loads the configuration from path on top of DefaultConfig.
+// Unknown fields are reported as errors so typos are not silently ignored.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path) // nolint: gosec
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	cfg := DefaultConfig()
+	if err := Parse(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
 
 	return cfg, nil
 }
 
+// Parse strictly decodes YAML data into cfg, keeping the values of fields
+// that data does not set
+func Parse(data []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Marshal encodes the configuration as YAML
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
 // Save saves the configuration to a YAML file
 func (c *Config) Save(path string) error {
-	data, err := yaml.Marshal(c)
+	data, err := c.Marshal()
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return err
 	}
 
 	dir := filepath.Dir(path)
@@ -119,25 +343,3 @@ func (c *Config) Save(path string) error {
 
 	return nil
 }
-
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	// Validate HTTP port
-	if c.HTTP.Port < 1 || c.HTTP.Port > 65535 {
-		return fmt.Errorf("invalid port number: %d", c.HTTP.Port)
-	}
-
-	// Validate metrics collection interval
-	if _, err := time.ParseDuration(c.Metrics.CollectionInterval); err != nil {
-		return fmt.Errorf("invalid collection interval: %s", c.Metrics.CollectionInterval)
-	}
-
-	// Validate log level
-	switch c.Logging.Level {
-	case "debug", "info", "warn", "error":
-	default:
-		return fmt.Errorf("invalid log level: %s", c.Logging.Level)
-	}
-
-	return nil
-}
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/certs/certstest"
 )
 
 func TestLoad(t *testing.T) {
@@ -30,6 +32,8 @@ func TestLoad(t *testing.T) {
 	require.False(t, cfg.Security.TLSEnabled)
 	require.Empty(t, cfg.Security.CertFile)
 	require.Empty(t, cfg.Security.KeyFile)
+	require.Empty(t, cfg.Security.AuthToken)
+	require.True(t, cfg.Security.PublicHealthCheck)
 }
 
 func TestLoadCustomConfig(t *testing.T) {
@@ -206,3 +210,103 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveAuthToken(t *testing.T) {
+	tmpDir := t.TempDir()
+	tokenFile := filepath.Join(tmpDir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("  file-token\n"), 0600))
+
+	emptyFile := filepath.Join(tmpDir, "empty")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0600))
+
+	sec := SecurityConfig{AuthToken: "inline", AuthTokenFile: tokenFile}
+	token, err := sec.ResolveAuthToken()
+	require.NoError(t, err)
+	require.Equal(t, "inline", token)
+
+	sec = SecurityConfig{AuthTokenFile: tokenFile}
+	token, err = sec.ResolveAuthToken()
+	require.NoError(t, err)
+	require.Equal(t, "file-token", token)
+
+	sec = SecurityConfig{AuthTokenFile: emptyFile}
+	_, err = sec.ResolveAuthToken()
+	require.ErrorContains(t, err, "security.auth_token_file: file is empty")
+
+	rw := RemoteWriteConfig{PasswordFile: filepath.Join(tmpDir, "missing")}
+	_, err = rw.ResolvePassword()
+	require.ErrorContains(t, err, "remote_write.password_file: failed to read file")
+
+	sec = SecurityConfig{}
+	token, err = sec.ResolveAuthToken()
+	require.NoError(t, err)
+	require.Empty(t, token)
+}
+
+func TestValidateTLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	ca := certstest.NewCA(t, "talis-ca")
+	certPEM, keyPEM := ca.IssueServer(t, "a")
+	otherPEM, _ := ca.IssueServer(t, "b")
+	certFile := certstest.WriteFile(t, tmpDir, "a-cert.pem", certPEM)
+	keyFile := certstest.WriteFile(t, tmpDir, "a-key.pem", keyPEM)
+	otherCert := certstest.WriteFile(t, tmpDir, "b-cert.pem", otherPEM)
+
+	tests := []struct {
+		name     string
+		security SecurityConfig
+		wantErr  bool
+	}{
+		{name: "valid key pair", security: SecurityConfig{TLSEnabled: true, CertFile: certFile, KeyFile: keyFile}},
+		{name: "disabled ignores files", security: SecurityConfig{CertFile: "missing.pem"}},
+		{name: "missing paths", security: SecurityConfig{TLSEnabled: true}, wantErr: true},
+		{name: "missing cert", security: SecurityConfig{TLSEnabled: true, CertFile: filepath.Join(tmpDir, "none.pem"), KeyFile: keyFile}, wantErr: true},
+		{name: "mismatched key", security: SecurityConfig{TLSEnabled: true, CertFile: otherCert, KeyFile: keyFile}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Security = tt.security
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClientAuth(t *testing.T) {
+	tmpDir := t.TempDir()
+	ca := certstest.NewCA(t, "talis-ca")
+	certPEM, keyPEM := ca.IssueServer(t, "server")
+	certFile := certstest.WriteFile(t, tmpDir, "server-cert.pem", certPEM)
+	keyFile := certstest.WriteFile(t, tmpDir, "server-key.pem", keyPEM)
+	caFile := certstest.WriteFile(t, tmpDir, "ca.pem", ca.PEM)
+	notPEM := filepath.Join(tmpDir, "not-pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("garbage"), 0600))
+
+	tests := []struct {
+		name     string
+		security SecurityConfig
+		wantErr  bool
+	}{
+		{name: "require with CA", security: SecurityConfig{TLSEnabled: true, CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire, ClientCAFile: caFile}},
+		{name: "none", security: SecurityConfig{ClientAuth: ClientAuthNone}},
+		{name: "unknown mode", security: SecurityConfig{ClientAuth: "maybe"}, wantErr: true},
+		{name: "without tls", security: SecurityConfig{ClientAuth: ClientAuthRequest, ClientCAFile: caFile}, wantErr: true},
+		{name: "without CA", security: SecurityConfig{TLSEnabled: true, CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequest}, wantErr: true},
+		{name: "invalid CA", security: SecurityConfig{TLSEnabled: true, CertFile: certFile, KeyFile: keyFile, ClientAuth: ClientAuthRequire, ClientCAFile: notPEM}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Security = tt.security
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix prefixes the environment variables that override config fields
+	EnvPrefix = "TALIS_AGENT_"
+	// EnvConfigFile names the environment variable holding the config file path
+	EnvConfigFile = EnvPrefix + "CONFIG"
+
+	// redacted replaces secret values when printing the configuration
+	redacted = "<redacted>"
+)
+
+// Field describes a single configuration setting
+type Field struct {
+	// Path is the dotted YAML path of the field, e.g. http.port
+	Path string
+	// Env is the environment variable that overrides the field
+	Env string
+	// Flag is the command-line flag that overrides the field, without dashes
+	Flag string
+	// Secret fields are redacted when the configuration is printed
+	Secret bool
+
+	value reflect.Value
+}
+
+// Fields returns every settable field of cfg in declaration order
+func Fields(cfg *Config) []Field {
+	var fields []Field
+	walkFields(reflect.ValueOf(cfg).Elem(), "", &fields)
+	return fields
+}
+
+// walkFields appends the leaf fields of the struct v to fields
+func walkFields(v reflect.Value, prefix string, fields *[]Field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := yamlName(sf)
+		if name == "" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			walkFields(fv, path, fields)
+			continue
+		}
+
+		*fields = append(*fields, Field{
+			Path:   path,
+			Env:    EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+			Flag:   path,
+			Secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+}
+
+// yamlName returns the YAML key of a struct field, or "" if it is skipped
+func yamlName(sf reflect.StructField) string {
+	if !sf.IsExported() {
+		return ""
+	}
+	name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+// set parses raw and stores it in the field. Scalars and string lists use
+// their plain text form; other types are parsed as YAML.
+func (f Field) set(raw string) error {
+	v := f.value
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	default:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(raw), "[") {
+			v.Set(reflect.ValueOf(splitList(raw)))
+			return nil
+		}
+		if v.Type() == reflect.TypeOf(map[string]string{}) && !strings.HasPrefix(strings.TrimSpace(raw), "{") {
+			m, err := splitMap(raw)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(m))
+			return nil
+		}
+		target := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(raw), target.Interface()); err != nil {
+			return fmt.Errorf("invalid value: %w", err)
+		}
+		v.Set(target.Elem())
+	}
+	return nil
+}
+
+// splitMap parses a comma separated list of key=value pairs
+func splitMap(raw string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, item := range splitList(raw) {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid key=value pair %q", item)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return m, nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(raw string) []string {
+	items := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ApplyEnv overrides fields of c from TALIS_AGENT_* environment variables
+// returned by lookup, typically os.LookupEnv
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	for _, field := range Fields(c) {
+		if raw, ok := lookup(field.Env); ok {
+			if err := field.set(raw); err != nil {
+				return fmt.Errorf("%s: %w", field.Env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Overrides holds config values set on the command line
+type Overrides struct {
+	values map[string]*flagValue
+}
+
+// flagValue is a flag.Value recording the raw value of a config flag
+type flagValue struct {
+	raw    string
+	isBool bool
+	set    bool
+}
+
+// String implements flag.Value
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.raw
+}
+
+// Set implements flag.Value
+func (v *flagValue) Set(raw string) error {
+	v.raw = raw
+	v.set = true
+	return nil
+}
+
+// IsBoolFlag lets boolean fields be set with a bare --flag
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// RegisterFlags adds a flag for every config field to fs, named after the
+// field's YAML path (e.g. --http.port)
+func RegisterFlags(fs *flag.FlagSet) *Overrides {
+	o := &Overrides{
+		values: make(map[string]*flagValue),
+	}
+	for _, field := range Fields(DefaultConfig()) {
+		value := &flagValue{isBool: field.value.Kind() == reflect.Bool}
+		o.values[field.Flag] = value
+		fs.Var(value, field.Flag, fmt.Sprintf("override %s (env %s)", field.Path, field.Env))
+	}
+	return o
+}
+
+// Apply overrides fields of cfg with the flags that were set
+func (o *Overrides) Apply(cfg *Config) error {
+	for _, field := range Fields(cfg) {
+		value := o.values[field.Flag]
+		if value == nil || !value.set {
+			continue
+		}
+		if err := field.set(value.raw); err != nil {
+			return fmt.Errorf("--%s: %w", field.Flag, err)
+		}
+	}
+	return nil
+}
+
+// FindFile returns the config file Resolve loads: path if set, else
+// $TALIS_AGENT_CONFIG, else the first existing file in the search paths. It
+// returns "" when there is no config file.
+func FindFile(path string, lookup func(string) (string, bool)) string {
+	if path != "" {
+		return path
+	}
+	if path, ok := lookup(EnvConfigFile); ok && path != "" {
+		return path
+	}
+	for _, path := range configPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Resolve builds the effective configuration. Values are applied in order
+// of increasing precedence: defaults, the config file found by FindFile,
+// TALIS_AGENT_* environment variables, then command-line flags.
+func Resolve(path string, lookup func(string) (string, bool), overrides *Overrides) (*Config, error) {
+	cfg := DefaultConfig()
+	if path = FindFile(path, lookup); path != "" {
+		var err error
+		if cfg, err = LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.ApplyEnv(lookup); err != nil {
+		return nil, err
+	}
+	if overrides != nil {
+		if err := overrides.Apply(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// Redacted returns a copy of c with secret values replaced
+func (c *Config) Redacted() (*Config, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	clone := &Config{}
+	if err := yaml.Unmarshal(data, clone); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	redactSecrets(reflect.ValueOf(clone).Elem())
+	return clone, nil
+}
+
+// redactSecrets replaces non-empty secret strings and the values of secret
+// maps in v and its children
+func redactSecrets(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if t.Field(i).Tag.Get("secret") == "true" && fv.Kind() == reflect.String && fv.String() != "" {
+				fv.SetString(redacted)
+				continue
+			}
+			if t.Field(i).Tag.Get("secret") == "true" && fv.Kind() == reflect.Map && fv.Type().Elem().Kind() == reflect.String {
+				for _, key := range fv.MapKeys() {
+					fv.SetMapIndex(key, reflect.ValueOf(redacted))
+				}
+				continue
+			}
+			redactSecrets(fv)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redactSecrets(v.Index(i))
+		}
+	}
+}
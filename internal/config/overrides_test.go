@@ -0,0 +1,151 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// envLookup returns a lookup function backed by env
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestFields(t *testing.T) {
+	fields := Fields(DefaultConfig())
+
+	byPath := make(map[string]Field)
+	for _, field := range fields {
+		byPath[field.Path] = field
+	}
+
+	port, ok := byPath["http.port"]
+	require.True(t, ok)
+	require.Equal(t, "TALIS_AGENT_HTTP_PORT", port.Env)
+	require.Equal(t, "http.port", port.Flag)
+	require.False(t, port.Secret)
+
+	token, ok := byPath["security.auth_token"]
+	require.True(t, ok)
+	require.True(t, token.Secret)
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv(envLookup(map[string]string{
+		"TALIS_AGENT_HTTP_PORT":                    "9000",
+		"TALIS_AGENT_LOGGING_LEVEL":                "debug",
+		"TALIS_AGENT_SECURITY_TLS_ENABLED":         "true",
+		"TALIS_AGENT_SECURITY_TOKENS":              "[{name: ci, token: secret, scopes: [metrics:read]}]",
+		"TALIS_AGENT_REMOTE_WRITE_EXTERNAL_LABELS": "network=mocha-4, role=validator",
+		"UNRELATED": "ignored",
+	}))
+	require.NoError(t, err)
+
+	require.Equal(t, 9000, cfg.HTTP.Port)
+	require.Equal(t, "debug", cfg.Logging.Level)
+	require.True(t, cfg.Security.TLSEnabled)
+	require.Equal(t, []TokenConfig{{Name: "ci", Token: "secret", Scopes: []string{"metrics:read"}}}, cfg.Security.Tokens)
+	require.Equal(t, map[string]string{"network": "mocha-4", "role": "validator"}, cfg.RemoteWrite.ExternalLabels)
+	require.Equal(t, "0.0.0.0", cfg.HTTP.Host)
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	tests := map[string]string{
+		"TALIS_AGENT_HTTP_PORT":                    "not-a-port",
+		"TALIS_AGENT_SECURITY_TLS_ENABLED":         "maybe",
+		"TALIS_AGENT_SECURITY_TOKENS":              "[{name: ci",
+		"TALIS_AGENT_REMOTE_WRITE_EXTERNAL_LABELS": "network",
+	}
+
+	for key, value := range tests {
+		t.Run(key, func(t *testing.T) {
+			err := DefaultConfig().ApplyEnv(envLookup(map[string]string{key: value}))
+			require.ErrorContains(t, err, key)
+		})
+	}
+}
+
+func TestOverridesApply(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--http.port=8081", "--security.tls_enabled", "--logging.format", "text"}))
+
+	cfg := DefaultConfig()
+	require.NoError(t, overrides.Apply(cfg))
+	require.Equal(t, 8081, cfg.HTTP.Port)
+	require.True(t, cfg.Security.TLSEnabled)
+	require.Equal(t, "text", cfg.Logging.Format)
+	require.Equal(t, "info", cfg.Logging.Level)
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides = RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--http.port=http"}))
+	require.ErrorContains(t, overrides.Apply(DefaultConfig()), "--http.port")
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	origPaths := configPaths
+	defer func() { configPaths = origPaths }()
+	SetConfigPaths([]string{})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("http:\n  port: 7000\n  host: 127.0.0.1\nlogging:\n  level: warn\n"), 0600))
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	overrides := RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--logging.level=error"}))
+
+	env := envLookup(map[string]string{
+		EnvConfigFile:               path,
+		"TALIS_AGENT_HTTP_PORT":     "7001",
+		"TALIS_AGENT_LOGGING_LEVEL": "debug",
+	})
+
+	cfg, err := Resolve("", env, overrides)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", cfg.HTTP.Host) // file
+	require.Equal(t, 7001, cfg.HTTP.Port)        // env over file
+	require.Equal(t, "error", cfg.Logging.Level) // flag over env
+	require.Equal(t, "json", cfg.Logging.Format) // default
+
+	_, err = Resolve(filepath.Join(t.TempDir(), "missing.yaml"), env, nil)
+	require.Error(t, err)
+
+	cfg, err = Resolve("", envLookup(nil), nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), cfg)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.AuthToken = "top-secret"
+	cfg.Security.Tokens = []TokenConfig{
+		{Name: "ci", Token: "also-secret", Scopes: []string{"*"}},
+		{Name: "file", TokenFile: "/etc/token"},
+	}
+	cfg.OTLP.Headers = map[string]string{"Authorization": "Bearer secret-header"}
+
+	redactedCfg, err := cfg.Redacted()
+	require.NoError(t, err)
+	require.Equal(t, redacted, redactedCfg.Security.AuthToken)
+	require.Equal(t, redacted, redactedCfg.Security.Tokens[0].Token)
+	require.Empty(t, redactedCfg.Security.Tokens[1].Token)
+	require.Equal(t, "/etc/token", redactedCfg.Security.Tokens[1].TokenFile)
+	require.Equal(t, map[string]string{"Authorization": redacted}, redactedCfg.OTLP.Headers)
+
+	// The original is left untouched
+	require.Equal(t, "top-secret", cfg.Security.AuthToken)
+	require.Equal(t, "also-secret", cfg.Security.Tokens[0].Token)
+	require.Equal(t, "Bearer secret-header", cfg.OTLP.Headers["Authorization"])
+
+	data, err := redactedCfg.Marshal()
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+}
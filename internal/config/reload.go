@@ -0,0 +1,225 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// DefaultWatchInterval is how often the config file is checked for changes
+const DefaultWatchInterval = 10 * time.Second
+
+// restartFields are the settings, or whole sections, that only take effect
+// after a restart
+var restartFields = map[string]bool{
+	"api":                  true,
+	"remote_write":         true,
+	"otlp":                 true,
+	"http.host":            true,
+	"http.port":            true,
+	"logging.format":       true,
+	"metrics.history_file": true,
+	"security.tls_enabled": true,
+	"security.client_auth": true,
+}
+
+// RequiresRestart reports whether a change to the field at path only takes
+// effect after the agent restarts
+func RequiresRestart(path string) bool {
+	section, _, _ := strings.Cut(path, ".")
+	return restartFields[path] || restartFields[section]
+}
+
+// PrepareFunc checks that a reloaded configuration can be applied to a
+// running component and returns a function that applies it. PrepareFunc must
+// not change the component itself, so that a configuration rejected by one
+// component leaves every component untouched.
+type PrepareFunc func(cfg *Config) (apply func(), err error)
+
+// Changes describes the outcome of a reload
+type Changes struct {
+	// Applied lists the fields that changed and took effect
+	Applied []string
+	// RestartRequired lists the fields that changed but keep their running
+	// value until the agent restarts
+	RestartRequired []string
+}
+
+// applier is a named PrepareFunc
+type applier struct {
+	name    string
+	prepare PrepareFunc
+}
+
+// fileState identifies the state of the config file on disk
+type fileState struct {
+	mod  time.Time
+	size int64
+}
+
+// Watcher reloads the configuration when the agent receives SIGHUP or the
+// config file changes. A new configuration is only applied if it is valid;
+// otherwise the running one is kept.
+type Watcher struct {
+	path string
+	load func() (*Config, error)
+
+	mu       sync.Mutex
+	current  *Config
+	state    fileState
+	appliers []applier
+}
+
+// NewWatcher returns a Watcher for the running configuration cfg. load
+// builds a new configuration, typically by calling Resolve again, and path
+// is the config file polled for changes, which may be empty.
+func NewWatcher(cfg *Config, path string, load func() (*Config, error)) *Watcher {
+	w := &Watcher{
+		path:    path,
+		load:    load,
+		current: cfg,
+	}
+	w.state, _ = w.stat()
+	return w
+}
+
+// OnReload registers prepare to be called with every reloaded
+// configuration. The returned functions are only called once every
+// component has prepared the configuration, in registration order.
+func (w *Watcher) OnReload(name string, prepare PrepareFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.appliers = append(w.appliers, applier{name: name, prepare: prepare})
+}
+
+// Current returns the running configuration
+func (w *Watcher) Current() *Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload loads and validates the configuration and applies it to the
+// registered components. Fields that require a restart keep their running
+// value. If loading, validation or preparing any component fails, no
+// component is changed, the running configuration is kept and an error is
+// returned.
+func (w *Watcher) Reload() (*Changes, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	state, _ := w.stat()
+	w.state = state
+
+	cfg, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	changes := &Changes{}
+	current, next := Fields(w.current), Fields(cfg)
+	for i := range next {
+		if reflect.DeepEqual(current[i].value.Interface(), next[i].value.Interface()) {
+			continue
+		}
+		if !RequiresRestart(next[i].Path) {
+			changes.Applied = append(changes.Applied, next[i].Path)
+			continue
+		}
+		changes.RestartRequired = append(changes.RestartRequired, next[i].Path)
+		next[i].value.Set(current[i].value)
+	}
+
+	// Components are re-applied even when nothing changed so that files
+	// referenced by the config, such as token files, are read again
+	applies := make([]func(), 0, len(w.appliers))
+	for _, a := range w.appliers {
+		apply, err := a.prepare(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply configuration to %s: %w", a.name, err)
+		}
+		applies = append(applies, apply)
+	}
+	for _, apply := range applies {
+		apply()
+	}
+
+	w.current = cfg
+	return changes, nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the config file
+// changes on disk, checking every interval, until ctx is done
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.reloadAndLog()
+		case <-ticker.C:
+			if w.changed() {
+				w.reloadAndLog()
+			}
+		}
+	}
+}
+
+// reloadAndLog reloads the configuration and logs the outcome
+func (w *Watcher) reloadAndLog() {
+	changes, err := w.Reload()
+	if err != nil {
+		logging.Error().Err(err).Str("path", w.path).Msg("Failed to reload configuration, keeping the current one")
+		return
+	}
+	if len(changes.RestartRequired) > 0 {
+		logging.Warn().Strs("fields", changes.RestartRequired).Msg("Configuration changes require a restart to take effect")
+	}
+	logging.Info().Strs("applied", changes.Applied).Str("path", w.path).Msg("Configuration reloaded")
+}
+
+// changed reports whether the config file differs from the loaded one
+func (w *Watcher) changed() bool {
+	state, err := w.stat()
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return state != w.state
+}
+
+// stat returns the current state of the config file
+func (w *Watcher) stat() (fileState, error) {
+	if w.path == "" {
+		return fileState{}, fmt.Errorf("no config file")
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{mod: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWatcher returns a Watcher for the config file at path
+func newTestWatcher(t *testing.T, path string) *Watcher {
+	t.Helper()
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	return NewWatcher(cfg, path, func() (*Config, error) {
+		return LoadFile(path)
+	})
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("http:\n  port: 8000\nlogging:\n  level: info\n"), 0600))
+	w := newTestWatcher(t, path)
+
+	var applied *Config
+	w.OnReload("test", func(cfg *Config) (func(), error) {
+		return func() { applied = cfg }, nil
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("http:\n  port: 9000\nlogging:\n  level: debug\n"), 0600))
+	changes, err := w.Reload()
+	require.NoError(t, err)
+	require.Equal(t, []string{"logging.level"}, changes.Applied)
+	require.Equal(t, []string{"http.port"}, changes.RestartRequired)
+
+	// Restart-only fields keep their running value
+	require.Same(t, applied, w.Current())
+	require.Equal(t, "debug", w.Current().Logging.Level)
+	require.Equal(t, 8000, w.Current().HTTP.Port)
+}
+
+func TestRequiresRestart(t *testing.T) {
+	require.True(t, RequiresRestart("http.port"))
+	require.True(t, RequiresRestart("api.base_url"))
+	require.True(t, RequiresRestart("api.checkin_interval"))
+	require.False(t, RequiresRestart("logging.level"))
+	require.False(t, RequiresRestart("security.tokens"))
+}
+
+func TestWatcherKeepsConfigOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: info\n"), 0600))
+	w := newTestWatcher(t, path)
+	current := w.Current()
+
+	calls := 0
+	w.OnReload("test", func(*Config) (func(), error) {
+		return func() { calls++ }, nil
+	})
+
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: loud\n"), 0600))
+	_, err := w.Reload()
+	require.ErrorContains(t, err, "invalid log level")
+
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  levle: debug\n"), 0600))
+	_, err = w.Reload()
+	require.Error(t, err)
+
+	require.Same(t, current, w.Current())
+	require.Zero(t, calls)
+
+	// A component that fails keeps the ones registered before it unchanged
+	w.OnReload("failing", func(*Config) (func(), error) {
+		return nil, errors.New("boom")
+	})
+	require.NoError(t, os.WriteFile(path, []byte("logging:\n  level: debug\n"), 0600))
+	_, err = w.Reload()
+	require.ErrorContains(t, err, "failing")
+	require.Same(t, current, w.Current())
+	require.Zero(t, calls)
+}
+
+func TestWatcherWatchesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("metrics:\n  collection_interval: 15s\n"), 0600))
+	w := newTestWatcher(t, path)
+
+	var mu sync.Mutex
+	var interval string
+	w.OnReload("test", func(cfg *Config) (func(), error) {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			interval = cfg.Metrics.CollectionInterval
+		}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Watch(ctx, 10*time.Millisecond)
+
+	// Ensure the modification time differs on filesystems with coarse timestamps
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(path, []byte("metrics:\n  collection_interval: 5s\n"), 0600))
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return interval == "5s"
+	}, 5*time.Second, 10*time.Millisecond)
+}
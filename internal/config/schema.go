@@ -0,0 +1,115 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// schemaDialect is the JSON Schema version Schema conforms to
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema describing the configuration file, derived
+// from the Config struct and its doc, enum, min, max and pattern tags.
+// Unknown fields are rejected, matching the strict parsing of LoadFile.
+func Schema() map[string]any {
+	schema := typeSchema(reflect.TypeOf(Config{}), reflect.ValueOf(DefaultConfig()).Elem())
+	schema["$schema"] = schemaDialect
+	schema["title"] = "Talis Agent configuration"
+	return schema
+}
+
+// typeSchema returns the schema of values of type t. def holds the default
+// value of the type and may be invalid when there is none.
+func typeSchema(t reflect.Type, def reflect.Value) map[string]any {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := yamlName(sf)
+			if name == "" {
+				continue
+			}
+			var fieldDef reflect.Value
+			if def.IsValid() {
+				fieldDef = def.Field(i)
+			}
+			properties[name] = fieldSchema(sf, fieldDef)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), reflect.Value{}),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), reflect.Value{}),
+		}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// namedEnums are value lists shared with other packages. A field tagged
+// enum:"@name" accepts the values of namedEnums[name].
+var namedEnums = map[string][]string{
+	"scopes": Scopes,
+}
+
+// enumValues returns the values allowed by the enum tag of sf, or nil
+func enumValues(sf reflect.StructField) []string {
+	enum := sf.Tag.Get("enum")
+	if enum == "" {
+		return nil
+	}
+	if name, ok := strings.CutPrefix(enum, "@"); ok {
+		return namedEnums[name]
+	}
+	return strings.Split(enum, ",")
+}
+
+// fieldSchema returns the schema of the struct field sf with default def
+func fieldSchema(sf reflect.StructField, def reflect.Value) map[string]any {
+	schema := typeSchema(sf.Type, def)
+
+	if doc := sf.Tag.Get("doc"); doc != "" {
+		schema["description"] = doc
+	}
+	if values := enumValues(sf); values != nil {
+		if items, ok := schema["items"].(map[string]any); ok {
+			items["enum"] = values
+		} else {
+			schema["enum"] = values
+		}
+	}
+	if pattern := sf.Tag.Get("pattern"); pattern != "" {
+		schema["pattern"] = pattern
+	}
+	if n, err := strconv.Atoi(sf.Tag.Get("min")); err == nil {
+		schema["minimum"] = n
+	}
+	if n, err := strconv.Atoi(sf.Tag.Get("max")); err == nil {
+		schema["maximum"] = n
+	}
+	if sf.Tag.Get("secret") == "true" {
+		schema["writeOnly"] = true
+	}
+
+	if def.IsValid() && def.Kind() != reflect.Struct && !((def.Kind() == reflect.Slice || def.Kind() == reflect.Map) && def.IsNil()) {
+		schema["default"] = def.Interface()
+	}
+	return schema
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateHeader is the comment at the top of the generated config file
+const templateHeader = `Talis Agent configuration
+Generated by "talis-agent config init". Every setting can also be
+overridden with a TALIS_AGENT_* environment variable or a command-line
+flag, e.g. TALIS_AGENT_HTTP_PORT or --http.port.`
+
+// Template returns the default configuration as YAML, with every field
+// documented from the doc, enum and example tags of the Config struct
+func Template() ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(DefaultConfig()); err != nil {
+		return nil, fmt.Errorf("failed to encode default config: %w", err)
+	}
+	annotate(&node, reflect.TypeOf(Config{}))
+
+	// Separate the top-level sections with blank lines
+	for i := 2; i < len(node.Content); i += 2 {
+		node.Content[i].HeadComment = "\n" + node.Content[i].HeadComment
+	}
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: templateHeader,
+		Content:     []*yaml.Node{&node},
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to marshal config template: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal config template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// annotate sets the comments of the keys of the mapping node from the
+// fields of the struct type t
+func annotate(node *yaml.Node, t reflect.Type) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		sf, ok := fieldByName(t, key.Value)
+		if !ok {
+			continue
+		}
+
+		key.HeadComment = fieldComment(sf)
+		if sf.Type.Kind() == reflect.Struct {
+			annotate(value, sf.Type)
+		}
+	}
+}
+
+// fieldByName returns the field of the struct type t with the YAML key name
+func fieldByName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); yamlName(sf) == name {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// fieldComment builds the comment documenting sf
+func fieldComment(sf reflect.StructField) string {
+	lines := []string{sf.Tag.Get("doc")}
+	if values := enumValues(sf); values != nil {
+		lines[0] += " (" + strings.Join(values, ", ") + ")"
+	}
+	if example := sf.Tag.Get("example"); example != "" {
+		lines = append(lines, "Example:")
+		for _, line := range strings.Split(example, "\n") {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
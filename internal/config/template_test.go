@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateParsesToDefaults(t *testing.T) {
+	data, err := Template()
+	require.NoError(t, err)
+
+	cfg := &Config{}
+	require.NoError(t, Parse(data, cfg))
+	require.Equal(t, DefaultConfig().Security.PublicHealthCheck, cfg.Security.PublicHealthCheck)
+
+	// Empty lists and maps are written as [] and {} rather than omitted
+	cfg.Security.Tokens, cfg.Security.ClientCerts = nil, nil
+	cfg.Metrics.DiskIncludeMountpoints, cfg.Metrics.DiskExcludeMountpoints = nil, nil
+	cfg.Metrics.DiskIncludeFSTypes, cfg.Metrics.DiskExcludeFSTypes = nil, nil
+	cfg.RemoteWrite.ExternalLabels = nil
+	cfg.OTLP.Headers, cfg.OTLP.ResourceAttributes = nil, nil
+	require.Equal(t, DefaultConfig(), cfg)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestTemplateDocumentsEveryField(t *testing.T) {
+	data, err := Template()
+	require.NoError(t, err)
+	template := string(data)
+
+	for _, field := range Fields(DefaultConfig()) {
+		t.Run(field.Path, func(t *testing.T) {
+			sf := structField(t, field.Path)
+			doc := sf.Tag.Get("doc")
+			require.NotEmpty(t, doc, "missing doc tag")
+			require.Contains(t, template, "# "+doc)
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	require.Equal(t, schemaDialect, schema["$schema"])
+	require.Equal(t, false, schema["additionalProperties"])
+
+	for _, field := range Fields(DefaultConfig()) {
+		t.Run(field.Path, func(t *testing.T) {
+			property := schema
+			for _, name := range strings.Split(field.Path, ".") {
+				properties, ok := property["properties"].(map[string]any)
+				require.True(t, ok)
+				property, ok = properties[name].(map[string]any)
+				require.True(t, ok, "missing property %s", name)
+			}
+			require.NotEmpty(t, property["type"])
+			require.NotEmpty(t, property["description"])
+		})
+	}
+
+	http := schema["properties"].(map[string]any)["http"].(map[string]any)
+	port := http["properties"].(map[string]any)["port"].(map[string]any)
+	require.Equal(t, "integer", port["type"])
+	require.Equal(t, 1, port["minimum"])
+	require.Equal(t, 65535, port["maximum"])
+	require.Equal(t, 25550, port["default"])
+
+	security := schema["properties"].(map[string]any)["security"].(map[string]any)
+	tokens := security["properties"].(map[string]any)["tokens"].(map[string]any)
+	require.Equal(t, "array", tokens["type"])
+	token := tokens["items"].(map[string]any)
+	scopes := token["properties"].(map[string]any)["scopes"].(map[string]any)
+	require.Equal(t, Scopes, scopes["items"].(map[string]any)["enum"])
+	require.Equal(t, true, token["properties"].(map[string]any)["token"].(map[string]any)["writeOnly"])
+}
+
+// structField returns the Config struct field at the dotted YAML path
+func structField(t *testing.T, path string) reflect.StructField {
+	t.Helper()
+
+	typ := reflect.TypeOf(Config{})
+	var sf reflect.StructField
+	for _, name := range strings.Split(path, ".") {
+		var ok bool
+		sf, ok = fieldByName(typ, name)
+		require.True(t, ok, "no field %s", name)
+		typ = sf.Type
+	}
+	return sf
+}
@@ -0,0 +1,403 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldError is a problem with the value of a single config field
+type FieldError struct {
+	// Path is the dotted YAML path of the field, e.g. security.cert_file
+	Path string
+	// Message describes the problem
+	Message string
+}
+
+// Error implements error
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validator collects field errors
+type validator struct {
+	errors []*FieldError
+}
+
+// addf records a problem with the field at path
+func (v *validator) addf(path, format string, args ...any) {
+	v.errors = append(v.errors, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// file checks that the file at path exists and is a regular file
+func (v *validator) file(path, name string) bool {
+	info, err := os.Stat(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		v.addf(path, "file not found: %s", name)
+	case err != nil:
+		v.addf(path, "%v", err)
+	case info.IsDir():
+		v.addf(path, "is a directory: %s", name)
+	default:
+		return true
+	}
+	return false
+}
+
+// secret checks that the secret file of the field at path can be read when
+// the inline value is not set
+func (v *validator) secret(path, value, file string) {
+	if _, err := readToken(path, value, file); err != nil {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			v.errors = append(v.errors, fieldErr)
+		} else {
+			v.addf(path, "%v", err)
+		}
+	}
+}
+
+// duration checks that value is a positive Go duration
+func (v *validator) duration(path, value string) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.addf(path, "invalid duration %q", value)
+	} else if d <= 0 {
+		v.addf(path, "must be positive, got %s", value)
+	}
+}
+
+// regexps checks that every pattern is a valid regular expression
+func (v *validator) regexps(path string, patterns []string) {
+	for i, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			v.addf(fmt.Sprintf("%s[%d]", path, i), "invalid regular expression %q: %v", pattern, err)
+		}
+	}
+}
+
+// scopes checks that every entry of values is a known scope
+func (v *validator) scopes(path string, values []string) {
+	for i, scope := range values {
+		known := false
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			v.addf(fmt.Sprintf("%s[%d]", path, i), "unknown scope %q (expected one of %s)", scope, strings.Join(Scopes, ", "))
+		}
+	}
+}
+
+// url checks that value is an absolute http or https URL
+func (v *validator) url(path, value string) {
+	u, err := url.Parse(value)
+	switch {
+	case err != nil:
+		v.addf(path, "%v", err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		v.addf(path, "must be an http or https URL, got %q", value)
+	case u.Host == "":
+		v.addf(path, "missing host in %q", value)
+	}
+}
+
+// Validate checks every section of the configuration and returns a
+// *ValidationError listing all problems found, or nil
+func (c *Config) Validate() error {
+	v := &validator{}
+	c.HTTP.validate(v)
+	c.Logging.validate(v)
+	c.Metrics.validate(v)
+	c.Security.validate(v)
+	c.API.validate(v, c.RemoteWrite.URL != "" || c.OTLP.Endpoint != "")
+	c.RemoteWrite.validate(v)
+	c.OTLP.validate(v)
+
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// validate checks the HTTP settings
+func (h *HTTPConfig) validate(v *validator) {
+	if h.Port < 1 || h.Port > 65535 {
+		v.addf("http.port", "must be between 1 and 65535, got %d", h.Port)
+	}
+	// An empty host listens on every interface
+	if h.Host != "" && net.ParseIP(h.Host) == nil && !validHostname(h.Host) {
+		v.addf("http.host", "invalid IP address or hostname %q", h.Host)
+	}
+}
+
+// validHostname reports whether host is a syntactically valid DNS name
+func validHostname(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validate checks the logging settings
+func (l *LoggingConfig) validate(v *validator) {
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		v.addf("logging.level", "invalid log level %q (expected debug, info, warn or error)", l.Level)
+	}
+	switch l.Format {
+	case "json", "text":
+	default:
+		v.addf("logging.format", "invalid log format %q (expected json or text)", l.Format)
+	}
+}
+
+// validate checks the metrics settings
+func (m *MetricsConfig) validate(v *validator) {
+	v.duration("metrics.collection_interval", m.CollectionInterval)
+	if m.RetentionDays < 1 {
+		v.addf("metrics.retention_days", "must be at least 1, got %d", m.RetentionDays)
+	}
+	v.regexps("metrics.disk_include_mountpoints", m.DiskIncludeMountpoints)
+	v.regexps("metrics.disk_exclude_mountpoints", m.DiskExcludeMountpoints)
+	v.regexps("metrics.disk_include_fstypes", m.DiskIncludeFSTypes)
+	v.regexps("metrics.disk_exclude_fstypes", m.DiskExcludeFSTypes)
+}
+
+// validate checks the security settings
+func (s *SecurityConfig) validate(v *validator) {
+	s.validateTLS(v)
+	s.validateTokens(v)
+	s.validateClientAuth(v)
+}
+
+// validateTLS checks that the certificate and key exist and belong together
+func (s *SecurityConfig) validateTLS(v *validator) {
+	if !s.TLSEnabled {
+		return
+	}
+
+	ok := true
+	if s.CertFile == "" {
+		v.addf("security.cert_file", "required when security.tls_enabled is true")
+		ok = false
+	} else if !v.file("security.cert_file", s.CertFile) {
+		ok = false
+	}
+	if s.KeyFile == "" {
+		v.addf("security.key_file", "required when security.tls_enabled is true")
+		ok = false
+	} else if !v.file("security.key_file", s.KeyFile) {
+		ok = false
+	}
+
+	if ok {
+		if _, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile); err != nil {
+			v.addf("security.key_file", "does not form a valid key pair with security.cert_file: %v", err)
+		}
+	}
+}
+
+// validateTokens checks the legacy token and the named tokens
+func (s *SecurityConfig) validateTokens(v *validator) {
+	v.secret("security.auth_token_file", s.AuthToken, s.AuthTokenFile)
+
+	names := make(map[string]bool)
+	for i, token := range s.Tokens {
+		path := fmt.Sprintf("security.tokens[%d]", i)
+		switch {
+		case token.Name == "":
+			v.addf(path+".name", "must not be empty")
+		case names[token.Name]:
+			v.addf(path+".name", "duplicate token name %q", token.Name)
+		}
+		names[token.Name] = true
+
+		switch {
+		case token.Token == "" && token.TokenFile == "":
+			v.addf(path, "either token or token_file must be set")
+		default:
+			v.secret(path+".token_file", token.Token, token.TokenFile)
+		}
+		v.scopes(path+".scopes", token.Scopes)
+	}
+}
+
+// validateClientAuth checks the client certificate settings
+func (s *SecurityConfig) validateClientAuth(v *validator) {
+	names := make(map[string]bool)
+	for i, cc := range s.ClientCerts {
+		path := fmt.Sprintf("security.client_certs[%d].common_name", i)
+		switch {
+		case cc.CommonName == "":
+			v.addf(path, "must not be empty")
+		case names[cc.CommonName]:
+			v.addf(path, "duplicate common name %q", cc.CommonName)
+		}
+		names[cc.CommonName] = true
+		v.scopes(fmt.Sprintf("security.client_certs[%d].scopes", i), cc.Scopes)
+	}
+
+	switch s.ClientAuth {
+	case "", ClientAuthNone:
+		return
+	case ClientAuthRequest, ClientAuthRequire:
+	default:
+		v.addf("security.client_auth", "invalid mode %q (expected none, request or require)", s.ClientAuth)
+		return
+	}
+
+	if !s.TLSEnabled {
+		v.addf("security.client_auth", "%s requires security.tls_enabled", s.ClientAuth)
+	}
+	if s.ClientCAFile == "" {
+		v.addf("security.client_ca_file", "required when security.client_auth is %s", s.ClientAuth)
+		return
+	}
+	if !v.file("security.client_ca_file", s.ClientCAFile) {
+		return
+	}
+	data, err := os.ReadFile(s.ClientCAFile)
+	if err != nil {
+		v.addf("security.client_ca_file", "%v", err)
+		return
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		v.addf("security.client_ca_file", "contains no PEM certificates: %s", s.ClientCAFile)
+	}
+}
+
+// validate checks the API client settings, which are only used when
+// base_url is set. The retry, rate limit and circuit breaker settings also
+// apply to the remote write and OTLP exporters, which are enabled when
+// exporters is true.
+func (a *APIConfig) validate(v *validator, exporters bool) {
+	if a.BaseURL != "" {
+		v.url("api.base_url", a.BaseURL)
+
+		v.secret("api.token_file", a.Token, a.TokenFile)
+
+		v.duration("api.request_timeout", a.RequestTimeout)
+		v.duration("api.checkin_interval", a.CheckinInterval)
+		if a.QueueDir != "" && a.QueueMaxSizeMB < 1 {
+			v.addf("api.queue_max_size_mb", "must be at least 1, got %d", a.QueueMaxSizeMB)
+		}
+	} else if !exporters {
+		return
+	}
+
+	v.duration("api.retry_delay", a.RetryDelay)
+	v.duration("api.reset_timeout", a.ResetTimeout)
+	if a.MaxRetries < 0 {
+		v.addf("api.max_retries", "must not be negative, got %d", a.MaxRetries)
+	}
+	if a.RateLimit <= 0 {
+		v.addf("api.rate_limit", "must be positive, got %g", a.RateLimit)
+	}
+	if a.BurstLimit < 1 {
+		v.addf("api.burst_limit", "must be at least 1, got %d", a.BurstLimit)
+	}
+	if a.FailureThreshold < 1 {
+		v.addf("api.failure_threshold", "must be at least 1, got %d", a.FailureThreshold)
+	}
+}
+
+// validate checks the remote write settings, which are only used when url
+// is set
+func (r *RemoteWriteConfig) validate(v *validator) {
+	if r.URL == "" {
+		return
+	}
+
+	v.url("remote_write.url", r.URL)
+	v.duration("remote_write.interval", r.Interval)
+	v.duration("remote_write.timeout", r.Timeout)
+
+	basicAuth := r.Username != "" || r.Password != "" || r.PasswordFile != ""
+	bearer := r.BearerToken != "" || r.BearerTokenFile != ""
+	switch {
+	case basicAuth && bearer:
+		v.addf("remote_write", "basic auth and bearer_token are mutually exclusive")
+	case basicAuth && r.Username == "":
+		v.addf("remote_write.username", "required when a basic auth password is set")
+	}
+	v.secret("remote_write.password_file", r.Password, r.PasswordFile)
+	v.secret("remote_write.bearer_token_file", r.BearerToken, r.BearerTokenFile)
+
+	names := make([]string, 0, len(r.ExternalLabels))
+	for name := range r.ExternalLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !validLabelName(name) {
+			v.addf("remote_write.external_labels."+name, "invalid label name %q", name)
+		}
+	}
+}
+
+// validate checks the OTLP exporter settings, which are only used when
+// endpoint is set
+func (o *OTLPConfig) validate(v *validator) {
+	if o.Endpoint == "" {
+		return
+	}
+
+	v.url("otlp.endpoint", o.Endpoint)
+	v.duration("otlp.interval", o.Interval)
+	v.duration("otlp.timeout", o.Timeout)
+	for name := range o.Headers {
+		if strings.TrimSpace(name) == "" {
+			v.addf("otlp.headers", "header names must not be empty")
+		}
+	}
+}
+
+// validLabelName reports whether name is a valid Prometheus label name that
+// is not reserved for internal use
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '_' && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
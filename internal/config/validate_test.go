@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// validationPaths returns the field paths reported by Validate
+func validationPaths(t *testing.T, cfg *Config) []string {
+	t.Helper()
+
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "unexpected error type %T", err)
+
+	paths := make([]string, len(verr.Errors))
+	for i, ferr := range verr.Errors {
+		paths[i] = ferr.Path
+	}
+	return paths
+}
+
+func TestValidateCollectsAllErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HTTP.Port = 0
+	cfg.HTTP.Host = "not a host"
+	cfg.Logging.Level = "loud"
+	cfg.Logging.Format = "xml"
+	cfg.Metrics.CollectionInterval = "-1s"
+	cfg.Metrics.RetentionDays = 0
+	cfg.Security.TLSEnabled = true
+
+	require.Equal(t, []string{
+		"http.port",
+		"http.host",
+		"logging.level",
+		"logging.format",
+		"metrics.collection_interval",
+		"metrics.retention_days",
+		"security.cert_file",
+		"security.key_file",
+	}, validationPaths(t, cfg))
+}
+
+func TestValidateHost(t *testing.T) {
+	for _, host := range []string{"", "0.0.0.0", "::1", "localhost", "agent-1.example.com"} {
+		cfg := DefaultConfig()
+		cfg.HTTP.Host = host
+		require.NoError(t, cfg.Validate(), host)
+	}
+	for _, host := range []string{"-bad.example.com", "a..b", "under_score"} {
+		cfg := DefaultConfig()
+		cfg.HTTP.Host = host
+		require.Equal(t, []string{"http.host"}, validationPaths(t, cfg), host)
+	}
+}
+
+func TestValidateDiskFilters(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Metrics.DiskIncludeMountpoints = []string{"/", "/mnt/celestia.*"}
+	cfg.Metrics.DiskExcludeFSTypes = []string{"tmpfs"}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Metrics.DiskExcludeMountpoints = []string{"/boot", "/snap/(.*"}
+	cfg.Metrics.DiskIncludeFSTypes = []string{"ext[4"}
+	require.Equal(t, []string{
+		"metrics.disk_exclude_mountpoints[1]",
+		"metrics.disk_include_fstypes[0]",
+	}, validationPaths(t, cfg))
+}
+
+func TestValidateAPI(t *testing.T) {
+	// The API settings are ignored while the client is disabled
+	cfg := DefaultConfig()
+	cfg.API.RequestTimeout = "soon"
+	require.NoError(t, cfg.Validate())
+
+	cfg.API.BaseURL = "ftp://talis.example.com"
+	cfg.API.TokenFile = filepath.Join(t.TempDir(), "missing")
+	cfg.API.RetryDelay = "0s"
+	cfg.API.MaxRetries = -1
+	cfg.API.RateLimit = 0
+	cfg.API.BurstLimit = 0
+	cfg.API.FailureThreshold = 0
+	cfg.API.QueueMaxSizeMB = 0
+	require.Equal(t, []string{
+		"api.base_url",
+		"api.token_file",
+		"api.request_timeout",
+		"api.queue_max_size_mb",
+		"api.retry_delay",
+		"api.max_retries",
+		"api.rate_limit",
+		"api.burst_limit",
+		"api.failure_threshold",
+	}, validationPaths(t, cfg))
+
+	// The exporters use the retry settings even without a base URL
+	cfg = DefaultConfig()
+	cfg.API.RequestTimeout = "soon"
+	cfg.API.RetryDelay = "0s"
+	cfg.OTLP.Endpoint = "http://collector:4318"
+	require.Equal(t, []string{"api.retry_delay"}, validationPaths(t, cfg))
+
+	cfg = DefaultConfig()
+	cfg.API.BaseURL = "https://talis.example.com/api/v1"
+	require.NoError(t, cfg.Validate())
+	cfg.API.BaseURL = "https:///api/v1"
+	require.Equal(t, []string{"api.base_url"}, validationPaths(t, cfg))
+}
+
+func TestValidateRemoteWrite(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RemoteWrite.Interval = "often"
+	require.NoError(t, cfg.Validate())
+
+	cfg.RemoteWrite.URL = "prometheus:9090"
+	cfg.RemoteWrite.Password = "secret"
+	cfg.RemoteWrite.BearerTokenFile = filepath.Join(t.TempDir(), "missing")
+	cfg.RemoteWrite.ExternalLabels = map[string]string{"network": "mocha-4", "1st": "x", "__name__": "y"}
+	require.Equal(t, []string{
+		"remote_write.url",
+		"remote_write.interval",
+		"remote_write",
+		"remote_write.bearer_token_file",
+		"remote_write.external_labels.1st",
+		"remote_write.external_labels.__name__",
+	}, validationPaths(t, cfg))
+
+	cfg = DefaultConfig()
+	cfg.RemoteWrite.URL = "https://prometheus.example.com/api/v1/write"
+	cfg.RemoteWrite.Password = "secret"
+	require.Equal(t, []string{"remote_write.username"}, validationPaths(t, cfg))
+	cfg.RemoteWrite.Username = "agent"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidateOTLP(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OTLP.Timeout = "never"
+	require.NoError(t, cfg.Validate())
+
+	cfg.OTLP.Endpoint = "otel-collector:4318"
+	cfg.OTLP.Interval = "0s"
+	require.Equal(t, []string{"otlp.endpoint", "otlp.interval", "otlp.timeout"}, validationPaths(t, cfg))
+
+	cfg = DefaultConfig()
+	cfg.OTLP.Endpoint = "http://otel-collector:4318/v1/metrics"
+	require.NoError(t, cfg.Validate())
+}
+
+func TestValidateFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.pem")
+
+	cfg := DefaultConfig()
+	cfg.Security.TLSEnabled = true
+	cfg.Security.CertFile = missing
+	cfg.Security.KeyFile = dir
+
+	err := cfg.Validate()
+	require.EqualError(t, err, "security.cert_file: file not found: "+missing+"; security.key_file: is a directory: "+dir)
+}
+
+func TestValidateTokens(t *testing.T) {
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0600))
+
+	cfg := DefaultConfig()
+	cfg.Security.AuthTokenFile = filepath.Join(t.TempDir(), "missing")
+	cfg.Security.Tokens = []TokenConfig{
+		{Name: "ok", Token: "a", Scopes: []string{"metrics:read", "*"}},
+		{Name: "ok", Token: "b"},
+		{Token: "c"},
+		{Name: "novalue"},
+		{Name: "empty", TokenFile: emptyFile},
+		{Name: "typo", Token: "d", Scopes: []string{"jobs:read", "metrics:write"}},
+	}
+	cfg.Security.ClientCerts = []ClientCertConfig{{CommonName: "a"}, {CommonName: "a"}, {}, {CommonName: "b", Scopes: []string{"all"}}}
+
+	require.Equal(t, []string{
+		"security.auth_token_file",
+		"security.tokens[1].name",
+		"security.tokens[2].name",
+		"security.tokens[3]",
+		"security.tokens[4].token_file",
+		"security.tokens[5].scopes[1]",
+		"security.client_certs[1].common_name",
+		"security.client_certs[2].common_name",
+		"security.client_certs[3].scopes[0]",
+	}, validationPaths(t, cfg))
+}
+
+func TestValidateClientAuthCrossField(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.ClientAuth = ClientAuthRequire
+
+	require.Equal(t, []string{"security.client_auth", "security.client_ca_file"}, validationPaths(t, cfg))
+}
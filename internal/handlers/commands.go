@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/auth"
+	"github.com/celestiaorg/talis-agent/internal/commands"
+	"github.com/celestiaorg/talis-agent/internal/jobs"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// ExecuteCommand handles the /commands endpoint. A JSON body is decoded as a
+// commands.Spec, any other body is executed as a bash script. With
+// ?async=true the command is started as a job and its ID returned immediately.
+func (h *Handler) ExecuteCommand(c *fiber.Ctx) error {
+	spec, err := parseCommandSpec(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if c.QueryBool("async") {
+		return h.submitJob(c, spec)
+	}
+
+	// Kill the command if the client goes away before it finishes
+	ctx, stop := watchConn(c.UserContext(), c.Context().Conn())
+	c.Context().SetConnectionClose()
+	result, err := commands.Run(ctx, h.executor, spec)
+	stop()
+	if err != nil {
+		if errors.Is(err, commands.ErrInvalidSpec) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		h.metrics.RecordCommandExecution(false)
+		logging.Error().Err(err).Msg("Failed to execute command")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.metrics.RecordCommandExecution(result.Success())
+
+	logging.Info().
+		Str("client_cert", auth.ClientSubjectFromFiber(c)).
+		Int("exit_code", result.ExitCode).
+		Bool("timed_out", result.TimedOut).
+		Int64("duration_ms", result.DurationMs).
+		Msg("Command executed")
+
+	return c.JSON(result)
+}
+
+// submitJob starts spec as a background job
+func (h *Handler) submitJob(c *fiber.Ctx, spec commands.Spec) error {
+	info, err := h.jobs.Submit(spec)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, commands.ErrInvalidSpec):
+			status = fiber.StatusBadRequest
+		case errors.Is(err, jobs.ErrTooManyJobs), errors.Is(err, jobs.ErrClosed):
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	logging.Info().
+		Str("job_id", info.ID).
+		Str("client_cert", auth.ClientSubjectFromFiber(c)).
+		Msg("Job submitted")
+
+	c.Location("/jobs/" + info.ID)
+	return c.Status(fiber.StatusAccepted).JSON(info)
+}
+
+// parseCommandSpec builds a commands.Spec from the request body
+func parseCommandSpec(c *fiber.Ctx) (commands.Spec, error) {
+	var spec commands.Spec
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		if err := c.BodyParser(&spec); err != nil {
+			return spec, err
+		}
+		return spec, nil
+	}
+
+	spec.Script = string(c.Body())
+	return spec, nil
+}
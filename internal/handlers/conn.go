@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// uploadReadTimeout bounds each read of a streamed request body. The server
+// read timeout covers the whole request, which a large upload can exceed.
+const uploadReadTimeout = 30 * time.Second
+
+// deadlineReader reads a request body from r, extending the read deadline
+// of conn before every read so only a stalled upload times out
+type deadlineReader struct {
+	r    io.Reader
+	conn net.Conn
+}
+
+// Read implements io.Reader
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if err := d.conn.SetReadDeadline(time.Now().Add(uploadReadTimeout)); err != nil {
+		logging.Debug().Err(err).Msg("Failed to extend upload read deadline")
+	}
+	return d.r.Read(p)
+}
+
+// watchConn returns a context derived from ctx that is cancelled when the
+// client closes conn, and a function that stops watching and must be called
+// before the response is written. The request must have been read in full.
+// The connection is not reused afterwards, since any pipelined request is
+// consumed while watching. In-memory connections, such as those of
+// fiber.App.Test, are not watched.
+func watchConn(ctx context.Context, conn net.Conn) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	switch conn.(type) {
+	case *net.TCPConn, *net.UnixConn, *tls.Conn:
+	default:
+		return ctx, cancel
+	}
+
+	// Lift the server read timeout, which would otherwise end the watch
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return ctx, cancel
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The client sends nothing until it has the response, so a read
+		// only returns once the connection is closed or the watch stops
+		var buf [1]byte
+		if _, err := conn.Read(buf[:]); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+			cancel()
+		}
+	}()
+
+	return ctx, func() {
+		if err := conn.SetReadDeadline(time.Now()); err != nil {
+			logging.Debug().Err(err).Msg("Failed to stop watching the connection")
+		}
+		<-done
+		cancel()
+	}
+}
@@ -7,19 +7,83 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
 
+	"github.com/celestiaorg/talis-agent/internal/commands"
+	"github.com/celestiaorg/talis-agent/internal/history"
+	"github.com/celestiaorg/talis-agent/internal/jobs"
 	"github.com/celestiaorg/talis-agent/internal/metrics"
+	"github.com/celestiaorg/talis-agent/internal/payload"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
 	collector *metrics.Collector
+	metrics   *metrics.PrometheusMetrics
+	payloads  *payload.Store
+	executor  commands.Executor
+	jobs      *jobs.Manager
+	history   *history.Store
+}
+
+// Option configures optional Handler dependencies
+type Option func(*Handler)
+
+// WithPayloadStore sets the store used by the /payload endpoint
+func WithPayloadStore(store *payload.Store) Option {
+	return func(h *Handler) {
+		h.payloads = store
+	}
+}
+
+// WithExecutor sets the executor used by the /commands endpoint
+func WithExecutor(executor commands.Executor) Option {
+	return func(h *Handler) {
+		h.executor = executor
+	}
+}
+
+// WithJobManager sets the manager used for asynchronous commands
+func WithJobManager(manager *jobs.Manager) Option {
+	return func(h *Handler) {
+		h.jobs = manager
+	}
+}
+
+// WithHistory sets the store served by the /metrics/history endpoint
+func WithHistory(store *history.Store) Option {
+	return func(h *Handler) {
+		h.history = store
+	}
 }
 
 // NewHandler creates a new Handler
-func NewHandler(collector *metrics.Collector) *Handler {
-	return &Handler{
+func NewHandler(collector *metrics.Collector, opts ...Option) *Handler {
+	h := &Handler{
 		collector: collector,
+		metrics:   metrics.GetPrometheusMetrics(),
+		payloads:  payload.NewStore(payload.DefaultDir),
+		executor:  commands.NewOSExecutor(),
+		history:   history.New(history.Config{}),
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.jobs == nil {
+		h.jobs = jobs.NewManager(jobs.ManagerConfig{
+			Executor: h.executor,
+			OnFinish: func(info jobs.Info) {
+				h.metrics.RecordCommandExecution(info.Status == jobs.StatusSucceeded)
+			},
+		})
+	}
+
+	return h
+}
+
+// Close cancels any running jobs and waits for them to exit
+func (h *Handler) Close() {
+	h.jobs.Close()
 }
 
 // HealthCheck handles the /alive endpoint
@@ -59,16 +123,3 @@ func (h *Handler) GetIP(c *fiber.Ctx) error {
 		"ips": ips,
 	})
 }
-
-// Endpoints returns a list of available endpoints
-func (h *Handler) Endpoints(c *fiber.Ctx) error {
-	endpoints := []string{
-		"/metrics",
-		"/alive",
-		"/ip",
-	}
-
-	return c.JSON(fiber.Map{
-		"endpoints": endpoints,
-	})
-}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/history"
+)
+
+const (
+	// defaultHistoryRange is the time range queried when from is omitted
+	defaultHistoryRange = time.Hour
+	// maxHistoryPoints bounds the number of points a query returns per series
+	maxHistoryPoints = 11000
+)
+
+// historyResponse is the body of a GET /metrics/history response
+type historyResponse struct {
+	Metric string           `json:"metric"`
+	From   time.Time        `json:"from"`
+	To     time.Time        `json:"to"`
+	Step   string           `json:"step,omitempty"`
+	Series []history.Series `json:"series"`
+}
+
+// GetMetricsHistory handles the GET /metrics/history endpoint. It returns
+// the recorded samples of ?metric= between ?from= and ?to=, given as RFC 3339
+// times or Unix timestamps and defaulting to the last hour, optionally
+// averaged over ?step= wide intervals. Without a step, ranges too long to
+// return every sample are averaged over the shortest whole-second step that
+// keeps them within maxHistoryPoints.
+func (h *Handler) GetMetricsHistory(c *fiber.Ctx) error {
+	metric := c.Query("metric")
+	if metric == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "metric is required",
+			"metrics": h.history.Names(),
+		})
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			return historyError(c, "to", err)
+		}
+		to = t
+	}
+	from := to.Add(-defaultHistoryRange)
+	if value := c.Query("from"); value != "" {
+		t, err := parseTime(value)
+		if err != nil {
+			return historyError(c, "from", err)
+		}
+		from = t
+	}
+	if from.After(to) {
+		return historyError(c, "from", fmt.Errorf("must not be after to"))
+	}
+
+	var step time.Duration
+	if value := c.Query("step"); value != "" {
+		var err error
+		step, err = time.ParseDuration(value)
+		if err != nil {
+			return historyError(c, "step", err)
+		}
+		if step <= 0 {
+			return historyError(c, "step", fmt.Errorf("must be positive"))
+		}
+		if to.Sub(from)/step > maxHistoryPoints {
+			return historyError(c, "step", fmt.Errorf("too small, the range would exceed %d points", maxHistoryPoints))
+		}
+	} else if minStep := to.Sub(from) / maxHistoryPoints; minStep >= time.Second {
+		step = (minStep + time.Second).Truncate(time.Second)
+	}
+
+	series, err := h.history.QueryLimit(metric, from, to, step, maxHistoryPoints)
+	if err != nil {
+		return historyError(c, "step", fmt.Errorf("required, the range has more than %d points", maxHistoryPoints))
+	}
+
+	resp := historyResponse{
+		Metric: metric,
+		From:   from.UTC(),
+		To:     to.UTC(),
+		Series: series,
+	}
+	if step > 0 {
+		resp.Step = step.String()
+	}
+	return c.JSON(resp)
+}
+
+// parseTime parses an RFC 3339 time or a Unix timestamp in seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or Unix timestamp, got %q", value)
+	}
+	return t, nil
+}
+
+// historyError responds with a bad request for the query parameter param
+func historyError(c *fiber.Ctx, param string, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": fmt.Sprintf("invalid %s: %v", param, err),
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/jobs"
+)
+
+// GetJob handles the GET /jobs/:id endpoint
+func (h *Handler) GetJob(c *fiber.Ctx) error {
+	info, err := h.jobs.Get(c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+	return c.JSON(info)
+}
+
+// GetJobOutput handles the GET /jobs/:id/output endpoint
+func (h *Handler) GetJobOutput(c *fiber.Ctx) error {
+	output, err := h.jobs.Output(c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+	return c.JSON(output)
+}
+
+// CancelJob handles the DELETE /jobs/:id endpoint
+func (h *Handler) CancelJob(c *fiber.Ctx) error {
+	info, err := h.jobs.Cancel(c.Params("id"))
+	if err != nil {
+		return jobError(c, err)
+	}
+	return c.JSON(info)
+}
+
+// jobError maps job manager errors to HTTP responses
+func jobError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, jobs.ErrFinished):
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
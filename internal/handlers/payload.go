@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+	"github.com/celestiaorg/talis-agent/internal/payload"
+)
+
+// UploadPayload handles the /payload endpoint. The request body is streamed
+// to disk and stored under the optional ?name= query parameter.
+func (h *Handler) UploadPayload(c *fiber.Ctx) error {
+	// Prefer the body stream so large payloads are never buffered in memory
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	} else {
+		body = &deadlineReader{r: body, conn: c.Context().Conn()}
+	}
+
+	result, err := h.payloads.Save(c.Query("name"), body)
+	if err != nil {
+		if errors.Is(err, payload.ErrInvalidName) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		logging.Error().Err(err).Msg("Failed to store payload")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	h.metrics.RecordPayloadReceived(result.Size)
+
+	logging.Info().
+		Str("path", result.Path).
+		Int64("size", result.Size).
+		Msg("Payload stored")
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
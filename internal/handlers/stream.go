@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+const (
+	// streamKeepAlive is how often a comment is sent on an idle stream
+	streamKeepAlive = 15 * time.Second
+	// streamWriteTimeout bounds each write to a streaming client
+	streamWriteTimeout = 30 * time.Second
+)
+
+// StreamJob handles the GET /jobs/:id/stream endpoint. Output lines are sent
+// as Server-Sent Events whose IDs are line sequence numbers, so a client can
+// resume with the Last-Event-ID header or the ?after= query parameter.
+func (h *Handler) StreamJob(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := h.jobs.Get(id); err != nil {
+		return jobError(c, err)
+	}
+
+	after, err := streamOffset(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// The server write timeout covers the whole response, so extend the
+	// deadline before every write instead
+	conn := c.Context().Conn()
+	extendDeadline := func() {
+		if err := conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+			logging.Debug().Err(err).Msg("Failed to extend stream write deadline")
+		}
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			lines, wait, done, err := h.jobs.Lines(id, after)
+			if err != nil {
+				// The job was evicted while streaming
+				extendDeadline()
+				writeEvent(w, "error", "", fiber.Map{"error": err.Error()})
+				_ = w.Flush()
+				return
+			}
+
+			extendDeadline()
+			for _, line := range lines {
+				writeEvent(w, "line", strconv.FormatUint(line.Seq, 10), line)
+				after = line.Seq
+			}
+
+			if done {
+				if info, err := h.jobs.Get(id); err == nil {
+					writeEvent(w, "end", "", info)
+				}
+				_ = w.Flush()
+				return
+			}
+
+			if err := w.Flush(); err != nil {
+				// Client went away
+				return
+			}
+
+			select {
+			case <-wait:
+			case <-keepAlive.C:
+				extendDeadline()
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+// streamOffset returns the last sequence number the client has seen
+func streamOffset(c *fiber.Ctx) (uint64, error) {
+	value := c.Query("after", c.Get("Last-Event-ID"))
+	if value == "" {
+		return 0, nil
+	}
+
+	after, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream offset: %s", value)
+	}
+	return after, nil
+}
+
+// writeEvent writes a single Server-Sent Event with a JSON payload
+func writeEvent(w *bufio.Writer, event, id string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logging.Error().Err(err).Msg("Failed to encode stream event")
+		return
+	}
+
+	if id != "" {
+		_, _ = fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/metrics"
+)
+
+// GetSystem handles the GET /system endpoint. It returns a snapshot of the
+// host as metrics.SystemMetrics, limited to the comma separated ?sections=
+// when given.
+func (h *Handler) GetSystem(c *fiber.Ctx) error {
+	sections, err := metrics.ParseSections(c.Query("sections"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	snapshot, err := h.collector.Snapshot(sections...)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, metrics.ErrNoCPUSample) {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(snapshot)
+}
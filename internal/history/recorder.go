@@ -0,0 +1,53 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+	"github.com/celestiaorg/talis-agent/internal/metrics"
+)
+
+// SaveInterval is how often Record saves the store to its file
+const SaveInterval = 10 * time.Minute
+
+// Record adds the metrics gathered from gatherer to the store every
+// interval(), which is re-read after each sample so interval changes take
+// effect, until ctx is done. The store is saved every SaveInterval and
+// when Record returns.
+func (s *Store) Record(ctx context.Context, gatherer prometheus.Gatherer, interval func() time.Duration) error {
+	logging.Info().
+		Str("interval", interval().String()).
+		Str("path", s.path).
+		Msg("Starting metrics history")
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	saveTicker := time.NewTicker(SaveInterval)
+	defer saveTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logging.Info().Msg("Stopping metrics history")
+			if err := s.Save(); err != nil {
+				logging.Error().Err(err).Msg("Failed to save metrics history")
+			}
+			return ctx.Err()
+		case <-timer.C:
+			s.sample(gatherer)
+			timer.Reset(interval())
+		case <-saveTicker.C:
+			if err := s.Save(); err != nil {
+				logging.Error().Err(err).Msg("Failed to save metrics history")
+			}
+		}
+	}
+}
+
+// sample adds the metrics currently gathered from gatherer
+func (s *Store) sample(gatherer prometheus.Gatherer) {
+	s.Add(metrics.NewBatch("", metrics.Gather(gatherer), s.now()).Samples)
+}
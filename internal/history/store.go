@@ -0,0 +1,359 @@
+package history
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/atomicfile"
+	"github.com/celestiaorg/talis-agent/internal/metrics"
+)
+
+const (
+	// DefaultRawRetention is how long samples are kept at full resolution
+	DefaultRawRetention = 24 * time.Hour
+	// DefaultResolution is the resolution samples are downsampled to once
+	// they are older than the raw retention
+	DefaultResolution = 5 * time.Minute
+)
+
+// ErrTooManyPoints is returned by QueryLimit when a series has more samples
+// in the queried range than the limit
+var ErrTooManyPoints = errors.New("too many points in range")
+
+// Config configures a Store
+type Config struct {
+	// Path is the file the store is saved to and loaded from, or "" to
+	// keep the history in memory only
+	Path string
+	// Retention is how long samples are kept. Zero means forever.
+	Retention time.Duration
+	// RawRetention is how long samples are kept at full resolution,
+	// DefaultRawRetention if zero
+	RawRetention time.Duration
+	// Resolution is the resolution older samples are downsampled to,
+	// DefaultResolution if zero
+	Resolution time.Duration
+}
+
+// Point is a value of a series at a point in time
+type Point struct {
+	TimestampMs int64   `json:"timestamp_ms"`
+	Value       float64 `json:"value"`
+}
+
+// Series is the result of a query for one series of a metric
+type Series struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Points []Point           `json:"points"`
+}
+
+// bucket aggregates the downsampled samples of a series that fall in one
+// resolution interval. Fields are exported for gob.
+type bucket struct {
+	StartMs int64
+	Sum     float64
+	Count   int
+}
+
+// series holds the samples of one metric and label set. Fields are
+// exported for gob.
+type series struct {
+	Name   string
+	Labels map[string]string
+	// Raw holds the samples newer than the raw retention, oldest first
+	Raw []Point
+	// Buckets holds the downsampled older samples, oldest first
+	Buckets []bucket
+}
+
+// Store is an embedded time-series store for the agent's own metrics.
+// Recent samples are kept at full resolution, older ones are averaged
+// into Resolution wide buckets, and samples beyond Retention are dropped.
+type Store struct {
+	path         string
+	rawRetention time.Duration
+	resolution   time.Duration
+	now          func() time.Time
+
+	mu        sync.RWMutex
+	retention time.Duration
+	series    map[string]*series
+}
+
+// New creates an empty store
+func New(cfg Config) *Store {
+	if cfg.RawRetention <= 0 {
+		cfg.RawRetention = DefaultRawRetention
+	}
+	if cfg.Resolution <= 0 {
+		cfg.Resolution = DefaultResolution
+	}
+	return &Store{
+		path:         cfg.Path,
+		retention:    cfg.Retention,
+		rawRetention: cfg.RawRetention,
+		resolution:   cfg.Resolution,
+		now:          time.Now,
+		series:       make(map[string]*series),
+	}
+}
+
+// Open creates a store and loads the history saved in cfg.Path by a
+// previous run, if any
+func Open(cfg Config) (*Store, error) {
+	s := New(cfg)
+	if s.path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics history: %w", err)
+	}
+	defer f.Close()
+
+	var saved []*series
+	if err := gob.NewDecoder(f).Decode(&saved); err != nil {
+		return nil, fmt.Errorf("failed to load metrics history from %s: %w", s.path, err)
+	}
+	for _, ser := range saved {
+		s.series[seriesKey(ser.Name, ser.Labels)] = ser
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compact()
+	return s, nil
+}
+
+// Save writes the store to its file. It does nothing for in-memory stores.
+func (s *Store) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	// Copy the series so that they can be encoded without holding the lock
+	s.mu.RLock()
+	saved := make([]*series, 0, len(s.series))
+	for _, ser := range s.series {
+		saved = append(saved, &series{
+			Name:    ser.Name,
+			Labels:  ser.Labels,
+			Raw:     append([]Point(nil), ser.Raw...),
+			Buckets: append([]bucket(nil), ser.Buckets...),
+		})
+	}
+	s.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("failed to create metrics history directory: %w", err)
+	}
+	err := atomicfile.Write(s.path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(saved)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save metrics history: %w", err)
+	}
+	return nil
+}
+
+// SetRetention changes how long samples are kept
+func (s *Store) SetRetention(retention time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+	s.compact()
+}
+
+// Add records samples. Samples that are not newer than the latest sample
+// of their series are ignored.
+func (s *Store) Add(samples []metrics.Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		key := seriesKey(sample.Name, sample.Labels)
+		ser, ok := s.series[key]
+		if !ok {
+			ser = &series{Name: sample.Name, Labels: sample.Labels}
+			s.series[key] = ser
+		}
+		if n := len(ser.Raw); n > 0 && sample.TimestampMs <= ser.Raw[n-1].TimestampMs {
+			continue
+		}
+		ser.Raw = append(ser.Raw, Point{TimestampMs: sample.TimestampMs, Value: sample.Value})
+	}
+	s.compact()
+}
+
+// Names returns the names of the recorded metrics in sorted order
+func (s *Store) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, ser := range s.series {
+		if !seen[ser.Name] {
+			seen[ser.Name] = true
+			names = append(names, ser.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns the samples of every series of metric between from and to,
+// inclusive, sorted by labels. With a positive step the samples are
+// averaged over step wide intervals starting at from.
+func (s *Store) Query(metric string, from, to time.Time, step time.Duration) []Series {
+	result, _ := s.QueryLimit(metric, from, to, step, 0)
+	return result
+}
+
+// QueryLimit is like Query but stops with ErrTooManyPoints as soon as a
+// series has more than limit samples. A limit of 0 means no limit.
+func (s *Store) QueryLimit(metric string, from, to time.Time, step time.Duration, limit int) ([]Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+	var keys []string
+	for key, ser := range s.series {
+		if ser.Name == metric {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// Without a step every sample is returned, so check the limit while
+	// collecting instead of after copying the whole range
+	tooMany := func(points []Point) bool {
+		return step <= 0 && limit > 0 && len(points) > limit
+	}
+
+	result := make([]Series, 0, len(keys))
+	for _, key := range keys {
+		ser := s.series[key]
+		var points []Point
+		for _, b := range ser.Buckets {
+			if b.StartMs >= fromMs && b.StartMs <= toMs {
+				points = append(points, Point{TimestampMs: b.StartMs, Value: b.Sum / float64(b.Count)})
+				if tooMany(points) {
+					return nil, ErrTooManyPoints
+				}
+			}
+		}
+		for _, p := range ser.Raw {
+			if p.TimestampMs >= fromMs && p.TimestampMs <= toMs {
+				points = append(points, p)
+				if tooMany(points) {
+					return nil, ErrTooManyPoints
+				}
+			}
+		}
+		if step > 0 {
+			points = resample(points, fromMs, step.Milliseconds())
+			if limit > 0 && len(points) > limit {
+				return nil, ErrTooManyPoints
+			}
+		}
+		if len(points) > 0 {
+			result = append(result, Series{Labels: ser.Labels, Points: points})
+		}
+	}
+	return result, nil
+}
+
+// compact downsamples samples older than the raw retention and drops
+// samples and series older than the retention. s.mu must be held.
+func (s *Store) compact() {
+	now := s.now()
+	rawCutoff := now.Add(-s.rawRetention).UnixMilli()
+	resolution := s.resolution.Milliseconds()
+
+	for key, ser := range s.series {
+		n := 0
+		for n < len(ser.Raw) && ser.Raw[n].TimestampMs < rawCutoff {
+			p := ser.Raw[n]
+			start := p.TimestampMs - p.TimestampMs%resolution
+			if last := len(ser.Buckets) - 1; last >= 0 && ser.Buckets[last].StartMs == start {
+				ser.Buckets[last].Sum += p.Value
+				ser.Buckets[last].Count++
+			} else {
+				ser.Buckets = append(ser.Buckets, bucket{StartMs: start, Sum: p.Value, Count: 1})
+			}
+			n++
+		}
+		ser.Raw = ser.Raw[n:]
+
+		if s.retention > 0 {
+			cutoff := now.Add(-s.retention).UnixMilli()
+			n = 0
+			for n < len(ser.Buckets) && ser.Buckets[n].StartMs < cutoff {
+				n++
+			}
+			ser.Buckets = ser.Buckets[n:]
+			n = 0
+			for n < len(ser.Raw) && ser.Raw[n].TimestampMs < cutoff {
+				n++
+			}
+			ser.Raw = ser.Raw[n:]
+		}
+
+		if len(ser.Raw) == 0 && len(ser.Buckets) == 0 {
+			delete(s.series, key)
+		}
+	}
+}
+
+// resample averages points over step wide intervals starting at fromMs.
+// Each interval is reported at its start; empty intervals are omitted.
+func resample(points []Point, fromMs, stepMs int64) []Point {
+	var result []Point
+	var sum float64
+	count := 0
+	for _, p := range points {
+		start := fromMs + (p.TimestampMs-fromMs)/stepMs*stepMs
+		if count > 0 && result[len(result)-1].TimestampMs != start {
+			result[len(result)-1].Value = sum / float64(count)
+			sum, count = 0, 0
+		}
+		if count == 0 {
+			result = append(result, Point{TimestampMs: start})
+		}
+		sum += p.Value
+		count++
+	}
+	if count > 0 {
+		result[len(result)-1].Value = sum / float64(count)
+	}
+	return result
+}
+
+// seriesKey identifies a series by its name and labels
+func seriesKey(name string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, label)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, label := range names {
+		fmt.Fprintf(&b, ",%s=%q", label, labels[label])
+	}
+	return b.String()
+}
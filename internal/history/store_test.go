@@ -0,0 +1,158 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/metrics"
+)
+
+// newTestStore returns a store whose clock is controlled by the returned
+// pointer
+func newTestStore(cfg Config) (*Store, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New(cfg)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// sample returns a sample of metric at t
+func sample(metric string, labels map[string]string, t time.Time, value float64) metrics.Sample {
+	return metrics.Sample{Name: metric, Labels: labels, Value: value, TimestampMs: t.UnixMilli()}
+}
+
+func TestStoreQuery(t *testing.T) {
+	s, now := newTestStore(Config{})
+	start := *now
+	for i := 0; i < 4; i++ {
+		ts := start.Add(time.Duration(i) * 15 * time.Second)
+		s.Add([]metrics.Sample{
+			sample("cpu", map[string]string{"core": "1"}, ts, float64(10*i)),
+			sample("cpu", map[string]string{"core": "0"}, ts, float64(i)),
+			sample("memory", nil, ts, 100),
+		})
+	}
+
+	// Samples that are not newer than the latest one are ignored
+	s.Add([]metrics.Sample{sample("memory", nil, start, 1)})
+
+	require.Equal(t, []string{"cpu", "memory"}, s.Names())
+
+	result := s.Query("cpu", start.Add(15*time.Second), start.Add(time.Minute), 0)
+	require.Len(t, result, 2)
+	require.Equal(t, map[string]string{"core": "0"}, result[0].Labels)
+	require.Equal(t, []Point{
+		{TimestampMs: start.Add(15 * time.Second).UnixMilli(), Value: 1},
+		{TimestampMs: start.Add(30 * time.Second).UnixMilli(), Value: 2},
+		{TimestampMs: start.Add(45 * time.Second).UnixMilli(), Value: 3},
+	}, result[0].Points)
+
+	// Samples are averaged over each step
+	result = s.Query("cpu", start, start.Add(time.Minute), 30*time.Second)
+	require.Equal(t, []Point{
+		{TimestampMs: start.UnixMilli(), Value: 5},
+		{TimestampMs: start.Add(30 * time.Second).UnixMilli(), Value: 25},
+	}, result[1].Points)
+
+	require.Empty(t, s.Query("missing", start, start.Add(time.Minute), 0))
+
+	// A limit stops queries returning more samples per series
+	_, err := s.QueryLimit("cpu", start, start.Add(time.Minute), 0, 2)
+	require.ErrorIs(t, err, ErrTooManyPoints)
+	result, err = s.QueryLimit("cpu", start, start.Add(time.Minute), 30*time.Second, 2)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+}
+
+func TestStoreDownsamplesAndExpires(t *testing.T) {
+	s, now := newTestStore(Config{Retention: 3 * time.Hour, RawRetention: time.Hour, Resolution: 10 * time.Minute})
+	start := *now
+	for i := 0; i < 20; i++ {
+		*now = start.Add(time.Duration(i) * time.Minute)
+		s.Add([]metrics.Sample{sample("load", nil, *now, float64(i))})
+	}
+
+	// After 70 minutes the first 10 minutes are averaged into one bucket
+	*now = start.Add(70*time.Minute + time.Second)
+	s.Add(nil)
+	result := s.Query("load", start, *now, 0)
+	require.Len(t, result, 1)
+	require.Equal(t, Point{TimestampMs: start.UnixMilli(), Value: 4.5}, result[0].Points[0])
+	require.Len(t, result[0].Points, 1+10)
+
+	// Samples older than the retention are dropped, and so are empty series
+	*now = start.Add(3*time.Hour + 10*time.Minute)
+	s.Add(nil)
+	result = s.Query("load", start, *now, 0)
+	require.Equal(t, []Point{{TimestampMs: start.Add(10 * time.Minute).UnixMilli(), Value: 14.5}}, result[0].Points)
+
+	s.SetRetention(time.Hour)
+	require.Empty(t, s.Names())
+}
+
+func TestStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "history.gob")
+	s, err := Open(Config{Path: path})
+	require.NoError(t, err)
+	now := time.Now()
+	s.Add([]metrics.Sample{sample("cpu", map[string]string{"core": "0"}, now, 42)})
+	require.NoError(t, s.Save())
+
+	s, err = Open(Config{Path: path})
+	require.NoError(t, err)
+	result := s.Query("cpu", now.Add(-time.Minute), now.Add(time.Minute), 0)
+	require.Equal(t, []Series{{
+		Labels: map[string]string{"core": "0"},
+		Points: []Point{{TimestampMs: now.UnixMilli(), Value: 42}},
+	}}, result)
+
+	// In-memory stores are not saved
+	require.NoError(t, New(Config{}).Save())
+}
+
+func TestStoreSaveWhileAdding(t *testing.T) {
+	s := New(Config{Path: filepath.Join(t.TempDir(), "history.gob")})
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			s.Add([]metrics.Sample{sample("cpu", nil, start.Add(time.Duration(i)*time.Millisecond), float64(i))})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Save())
+	}
+	<-done
+}
+
+func TestStoreRecord(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"})
+	registry.MustRegister(gauge)
+	gauge.Set(3)
+
+	path := filepath.Join(t.TempDir(), "history.gob")
+	s := New(Config{Path: path})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Record(ctx, registry, func() time.Duration { return 10 * time.Millisecond })
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(s.Query("test_gauge", time.Now().Add(-time.Minute), time.Now(), 0)) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// The store is saved when recording stops
+	s, err := Open(Config{Path: path})
+	require.NoError(t, err)
+	require.Equal(t, []string{"test_gauge"}, s.Names())
+}
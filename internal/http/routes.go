@@ -0,0 +1,120 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/celestiaorg/talis-agent/internal/auth"
+	"github.com/celestiaorg/talis-agent/internal/handlers"
+)
+
+// Route is an endpoint of the agent API
+type Route struct {
+	Method string
+	Path   string
+	// Scope is the scope callers must be granted, or "" if any
+	// authenticated caller may use the route
+	Scope       string
+	Description string
+	Handler     fiber.Handler
+}
+
+// RouteInfo describes a Route in the endpoint listing
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Scope       string `json:"scope,omitempty"`
+	Description string `json:"description"`
+}
+
+// Routes returns every route of the agent API served by h, except the
+// endpoint listing at / which the Server adds
+func Routes(h *handlers.Handler) []Route {
+	return []Route{
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/alive",
+			Description: "Health check",
+			Handler:     h.HealthCheck,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/metrics",
+			Scope:       auth.ScopeMetricsRead,
+			Description: "System metrics in Prometheus format",
+			Handler:     h.GetMetrics,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/metrics/history",
+			Scope:       auth.ScopeMetricsRead,
+			Description: "Recorded samples of ?metric= between ?from= and ?to=, averaged over ?step=",
+			Handler:     h.GetMetricsHistory,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/system",
+			Scope:       auth.ScopeMetricsRead,
+			Description: "JSON snapshot of the host, limited to ?sections= when given",
+			Handler:     h.GetSystem,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/ip",
+			Scope:       auth.ScopeIPRead,
+			Description: "Non-loopback IPv4 addresses of the host",
+			Handler:     h.GetIP,
+		},
+		{
+			Method:      fiber.MethodPost,
+			Path:        "/payload",
+			Scope:       auth.ScopePayloadWrite,
+			Description: "Upload a file to the payload directory",
+			Handler:     h.UploadPayload,
+		},
+		{
+			Method:      fiber.MethodPost,
+			Path:        "/commands",
+			Scope:       auth.ScopeCommandsExec,
+			Description: "Run a command, or start a job with ?async=true",
+			Handler:     h.ExecuteCommand,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/jobs/:id",
+			Scope:       auth.ScopeJobsRead,
+			Description: "Status of an asynchronous job",
+			Handler:     h.GetJob,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/jobs/:id/output",
+			Scope:       auth.ScopeJobsRead,
+			Description: "Captured output of a job",
+			Handler:     h.GetJobOutput,
+		},
+		{
+			Method:      fiber.MethodGet,
+			Path:        "/jobs/:id/stream",
+			Scope:       auth.ScopeJobsRead,
+			Description: "Follow the output of a job as Server-Sent Events",
+			Handler:     h.StreamJob,
+		},
+		{
+			Method:      fiber.MethodDelete,
+			Path:        "/jobs/:id",
+			Scope:       auth.ScopeCommandsExec,
+			Description: "Cancel a running job",
+			Handler:     h.CancelJob,
+		},
+	}
+}
+
+// info returns the listing entry of the route
+func (r Route) info() RouteInfo {
+	return RouteInfo{
+		Method:      r.Method,
+		Path:        r.Path,
+		Scope:       r.Scope,
+		Description: r.Description,
+	}
+}
@@ -2,33 +2,109 @@ package http
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"crypto/tls"
 	"fmt"
 	"net"
-	"net/http"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 
+	"github.com/celestiaorg/talis-agent/internal/auth"
+	"github.com/celestiaorg/talis-agent/internal/certs"
 	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/handlers"
 	"github.com/celestiaorg/talis-agent/internal/logging"
 )
 
-// ErrServerClosed is returned by the Server's Start method after a call to Shutdown
-var ErrServerClosed = errors.New("http: Server closed")
+// shutdownTimeout bounds how long Serve waits for open requests on shutdown
+const shutdownTimeout = 30 * time.Second
 
-// Server represents the HTTP server
+// Server serves the agent API. Every endpoint is registered from a single
+// route table so the binary and tests serve identical routes.
 type Server struct {
-	config *config.Config
-	srv    *http.Server
+	config        *config.Config
+	app           *fiber.App
+	routes        []Route
+	authenticator *auth.Authenticator
+	reloader      *certs.Reloader
 }
 
-// NewServer creates a new HTTP server
-func NewServer(config *config.Config) *Server {
-	return &Server{
-		config: config,
+// NewServer creates a server for the routes served by h, configured from
+// cfg. It fails if the authentication or TLS settings cannot be loaded.
+func NewServer(cfg *config.Config, h *handlers.Handler) (*Server, error) {
+	authenticator, err := auth.New(cfg.Security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure authentication: %w", err)
+	}
+
+	s := &Server{
+		config:        cfg,
+		routes:        Routes(h),
+		authenticator: authenticator,
+	}
+
+	if cfg.Security.TLSEnabled {
+		s.reloader, err = certs.FromConfig(cfg.Security)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure TLS: %w", err)
+		}
 	}
+
+	s.app = fiber.New(fiber.Config{
+		AppName: "Talis Agent",
+		// The write timeout starts once a handler returns. Handlers that read
+		// for longer, such as /payload uploads, extend the read deadline
+		// themselves, and synchronous /commands lift it while they run.
+		ReadTimeout:  time.Second * 10,
+		WriteTimeout: time.Second * 10,
+		// Stream request bodies so /payload uploads are not buffered in memory
+		StreamRequestBody: true,
+	})
+
+	s.app.Use(logger.New(logger.Config{
+		// Record the token name and client certificate of each request
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:token} | ${locals:client_cert} | ${error}\n",
+	}))
+	s.app.Use(recover.New())
+	s.app.Use(cors.New())
+
+	// Require a bearer token when one is configured
+	s.app.Use(authenticator.Fiber())
+
+	s.app.Get("/", s.handleIndex)
+	for _, route := range s.routes {
+		chain := []fiber.Handler{route.Handler}
+		if route.Scope != "" {
+			chain = append([]fiber.Handler{auth.Require(route.Scope)}, chain...)
+		}
+		s.app.Add(route.Method, route.Path, chain...)
+	}
+
+	return s, nil
+}
+
+// App returns the Fiber app serving the routes
+func (s *Server) App() *fiber.App {
+	return s.app
+}
+
+// Routes returns the registered routes
+func (s *Server) Routes() []Route {
+	return s.routes
+}
+
+// Authenticator returns the authenticator checking requests
+func (s *Server) Authenticator() *auth.Authenticator {
+	return s.authenticator
+}
+
+// Reloader returns the TLS certificate reloader, or nil if TLS is disabled.
+// Serve does not watch the certificate files; callers run Reloader.Watch.
+func (s *Server) Reloader() *certs.Reloader {
+	return s.reloader
 }
 
 // Address returns the server's address
@@ -36,103 +112,62 @@ func (s *Server) Address() string {
 	return fmt.Sprintf("%s:%d", s.config.HTTP.Host, s.config.HTTP.Port)
 }
 
-// Start starts the HTTP server
+// Start listens on the configured address and serves until ctx is done
 func (s *Server) Start(ctx context.Context) error {
-	mux := http.NewServeMux()
-
-	// Register routes
-	mux.HandleFunc("/alive", s.handleHealthCheck)
-	mux.HandleFunc("/metrics", s.handleMetrics)
-	mux.HandleFunc("/ip", s.handleIP)
-
-	s.srv = &http.Server{
-		Addr:              s.Address(),
-		Handler:           mux,
-		ReadHeaderTimeout: 20 * time.Second, // Prevent slow HTTP header attacks
-		ReadTimeout:       1 * time.Minute,  // Maximum duration for reading entire request
-		WriteTimeout:      2 * time.Minute,  // Maximum duration for writing response
-		IdleTimeout:       2 * time.Minute,  // Maximum duration to wait for the next request
-		MaxHeaderBytes:    1 << 20,          // Maximum size of request headers (1MB)
+	ln, err := net.Listen("tcp", s.Address())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.Address(), err)
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln, wrapped in TLS when enabled, until ctx
+// is done, then waits for open requests to finish
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if s.reloader != nil {
+		ln = tls.NewListener(ln, s.reloader.TLSConfig())
 	}
 
-	logging.Info().Str("address", s.Address()).Msg("Starting HTTP server")
+	logging.Info().
+		Str("address", ln.Addr().String()).
+		Bool("tls", s.reloader != nil).
+		Msg("Starting HTTP server")
 
 	errCh := make(chan error, 1)
 	go func() {
-		if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- fmt.Errorf("server error: %w", err)
-		}
-		close(errCh)
+		errCh <- s.app.Listener(ln)
 	}()
 
 	select {
 	case err := <-errCh:
-		return err
+		if err != nil {
+			return fmt.Errorf("server error: %w", err)
+		}
+		return nil
 	case <-ctx.Done():
-		// Create a timeout context for shutdown
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// Attempt graceful shutdown
-		if err := s.srv.Shutdown(shutdownCtx); err != nil {
-			// If shutdown times out, force close
+		if err := s.app.ShutdownWithTimeout(shutdownTimeout); err != nil {
 			logging.Error().Err(err).Msg("Server shutdown timed out, forcing close")
-			return s.srv.Close()
+			return err
 		}
-		return nil
-	}
-}
-
-func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
-		logging.Error().Err(err).Msg("Failed to encode response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		return <-errCh
 	}
 }
 
-func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	promhttp.Handler().ServeHTTP(w, r)
-}
-
-func (s *Server) handleIP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	var ips []string
-	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP.String())
-			}
+// handleIndex lists the available endpoints
+func (s *Server) handleIndex(c *fiber.Ctx) error {
+	var endpoints []string
+	seen := make(map[string]bool)
+	routes := make([]RouteInfo, 0, len(s.routes))
+	for _, route := range s.routes {
+		if !seen[route.Path] {
+			seen[route.Path] = true
+			endpoints = append(endpoints, route.Path)
 		}
+		routes = append(routes, route.info())
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(map[string][]string{"ips": ips}); err != nil {
-		logging.Error().Err(err).Msg("Failed to encode response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+	return c.JSON(fiber.Map{
+		"endpoints": endpoints,
+		"routes":    routes,
+	})
 }
@@ -2,103 +2,206 @@ package http
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/celestiaorg/talis-agent/internal/auth"
+	"github.com/celestiaorg/talis-agent/internal/commands"
 	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/handlers"
+	"github.com/celestiaorg/talis-agent/internal/metrics"
 )
 
+// newTestServer creates a Server for cfg backed by a fake executor
+func newTestServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+
+	h := handlers.NewHandler(metrics.NewCollector(15*time.Second), handlers.WithExecutor(&commands.FakeExecutor{}))
+	t.Cleanup(h.Close)
+
+	server, err := NewServer(cfg, h)
+	require.NoError(t, err)
+	return server
+}
+
 func TestNewServer(t *testing.T) {
-	cfg := &config.Config{
-		HTTP: config.HTTPConfig{
-			Host: "localhost",
-			Port: 25550,
-		},
-		Metrics: config.MetricsConfig{
-			CollectionInterval: "15s",
-			RetentionDays:      7,
-		},
-		Logging: config.LoggingConfig{
-			Level:  "info",
-			Format: "json",
-		},
-		Security: config.SecurityConfig{
-			TLSEnabled: false,
-			CertFile:   "",
-			KeyFile:    "",
-		},
-	}
+	cfg := config.DefaultConfig()
 
-	server := NewServer(cfg)
+	server := newTestServer(t, cfg)
 	require.NotNil(t, server)
 	require.Equal(t, cfg, server.config)
+	require.Nil(t, server.Reloader())
+	require.False(t, server.Authenticator().Enabled())
+}
+
+func TestNewServerInvalidTLS(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.TLSEnabled = true
+	cfg.Security.CertFile = "missing.pem"
+	cfg.Security.KeyFile = "missing-key.pem"
+
+	_, err := NewServer(cfg, handlers.NewHandler(metrics.NewCollector(15*time.Second)))
+	require.Error(t, err)
 }
 
 func TestServerAddress(t *testing.T) {
-	cfg := &config.Config{
-		HTTP: config.HTTPConfig{
-			Host: "localhost",
-			Port: 25550,
-		},
-		Metrics: config.MetricsConfig{
-			CollectionInterval: "15s",
-			RetentionDays:      7,
-		},
-		Logging: config.LoggingConfig{
-			Level:  "info",
-			Format: "json",
-		},
-		Security: config.SecurityConfig{
-			TLSEnabled: false,
-			CertFile:   "",
-			KeyFile:    "",
-		},
-	}
+	cfg := config.DefaultConfig()
+	cfg.HTTP.Host = "localhost"
 
-	server := NewServer(cfg)
+	server := newTestServer(t, cfg)
 	require.Equal(t, "localhost:25550", server.Address())
 }
 
-func TestServerStart(t *testing.T) {
-	cfg := &config.Config{
-		HTTP: config.HTTPConfig{
-			Host: "localhost",
-			Port: 0, // Use port 0 to let the OS assign a random port
-		},
-		Metrics: config.MetricsConfig{
-			CollectionInterval: "15s",
-			RetentionDays:      7,
-		},
-		Logging: config.LoggingConfig{
-			Level:  "info",
-			Format: "json",
-		},
-		Security: config.SecurityConfig{
-			TLSEnabled: false,
-			CertFile:   "",
-			KeyFile:    "",
-		},
-	}
-
-	server := NewServer(cfg)
-	require.NotNil(t, server)
+func TestServerServe(t *testing.T) {
+	server := newTestServer(t, config.DefaultConfig())
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
 
-	// Start server in background
+	ctx, cancel := context.WithCancel(context.Background())
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- server.Start(ctx)
+		errCh <- server.Serve(ctx, ln)
 	}()
 
-	// Wait for context to be done
+	url := fmt.Sprintf("http://%s/alive", ln.Addr())
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url) // nolint: gosec
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
 	select {
 	case err := <-errCh:
 		require.NoError(t, err)
-	case <-ctx.Done():
-		// Expected timeout
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
+
+// blockingExecutor runs until its context is done
+type blockingExecutor struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+// Execute implements commands.Executor
+func (e *blockingExecutor) Execute(ctx context.Context, _ commands.Spec, _, _ io.Writer) (int, error) {
+	close(e.started)
+	<-ctx.Done()
+	close(e.stopped)
+	return -1, nil
+}
+
+func TestCommandStopsWhenClientDisconnects(t *testing.T) {
+	executor := &blockingExecutor{started: make(chan struct{}), stopped: make(chan struct{})}
+	h := handlers.NewHandler(metrics.NewCollector(15*time.Second), handlers.WithExecutor(executor))
+	t.Cleanup(h.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Security.AllowAnonymous = true
+	server, err := NewServer(cfg, h)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = server.Serve(ctx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(conn, "POST /commands HTTP/1.1\r\nHost: agent\r\nContent-Length: 5\r\n\r\nsleep")
+	require.NoError(t, err)
+
+	select {
+	case <-executor.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not start")
+	}
+	require.NoError(t, conn.Close())
+
+	select {
+	case <-executor.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("command kept running after the client disconnected")
+	}
+}
+
+func TestIndexListsRoutes(t *testing.T) {
+	server := newTestServer(t, config.DefaultConfig())
+
+	resp, err := server.App().Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Endpoints []string    `json:"endpoints"`
+		Routes    []RouteInfo `json:"routes"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Routes, len(server.Routes()))
+	require.Contains(t, body.Endpoints, "/metrics")
+	require.Contains(t, body.Endpoints, "/jobs/:id/stream")
+	require.Contains(t, body.Routes, RouteInfo{
+		Method:      http.MethodGet,
+		Path:        "/ip",
+		Scope:       auth.ScopeIPRead,
+		Description: "Non-loopback IPv4 addresses of the host",
+	})
+}
+
+func TestRoutesRequireScopes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.Tokens = []config.TokenConfig{
+		{Name: "reader", Token: "reader-token", Scopes: []string{auth.ScopeMetricsRead}},
+	}
+	server := newTestServer(t, cfg)
+
+	for _, route := range server.Routes() {
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			path := strings.ReplaceAll(route.Path, ":id", "missing")
+			req := httptest.NewRequest(route.Method, path, nil)
+			req.Header.Set("Authorization", "Bearer reader-token")
+
+			resp, err := server.App().Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			switch route.Scope {
+			case "", auth.ScopeMetricsRead:
+				require.NotEqual(t, http.StatusForbidden, resp.StatusCode)
+			default:
+				require.Equal(t, http.StatusForbidden, resp.StatusCode)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+		})
 	}
 }
+
+func TestHealthCheck(t *testing.T) {
+	server := newTestServer(t, config.DefaultConfig())
+
+	resp, err := server.App().Test(httptest.NewRequest(http.MethodGet, "/alive", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "ok", body["status"])
+}
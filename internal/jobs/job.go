@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/commands"
+)
+
+// Status represents the lifecycle state of a job
+type Status string
+
+const (
+	// StatusRunning means the command is still executing
+	StatusRunning Status = "running"
+	// StatusSucceeded means the command exited with status zero
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed means the command exited non-zero or could not be started
+	StatusFailed Status = "failed"
+	// StatusCanceled means the job was canceled before the command finished
+	StatusCanceled Status = "canceled"
+	// StatusTimedOut means the command was killed after its timeout elapsed
+	StatusTimedOut Status = "timed_out"
+)
+
+// Finished reports whether the status is terminal
+func (s Status) Finished() bool {
+	return s != StatusRunning
+}
+
+// Info is a point-in-time snapshot of a job. The spec is reported without
+// its environment, which may hold secrets; only the variable names are kept.
+type Info struct {
+	ID         string        `json:"id"`
+	Spec       commands.Spec `json:"spec"`
+	EnvNames   []string      `json:"env_names,omitempty"`
+	Status     Status        `json:"status"`
+	ExitCode   *int          `json:"exit_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	DurationMs int64         `json:"duration_ms"`
+}
+
+// Output holds the output captured from a job
+type Output struct {
+	ID              string `json:"id"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+}
+
+// job is a command running in the background
+type job struct {
+	id     string
+	spec   commands.Spec
+	cancel context.CancelFunc
+	done   chan struct{}
+	stdout *commands.OutputBuffer
+	stderr *commands.OutputBuffer
+	log    *lineLog
+
+	mu         sync.RWMutex
+	status     Status
+	exitCode   *int
+	err        string
+	canceled   bool
+	startedAt  time.Time
+	finishedAt time.Time
+}
+
+// info returns a snapshot of the job
+func (j *job) info() Info {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	info := Info{
+		ID:        j.id,
+		Spec:      j.spec,
+		Status:    j.status,
+		ExitCode:  j.exitCode,
+		Error:     j.err,
+		StartedAt: j.startedAt,
+	}
+	info.Spec.Env = nil
+	for name := range j.spec.Env {
+		info.EnvNames = append(info.EnvNames, name)
+	}
+	sort.Strings(info.EnvNames)
+
+	if j.status.Finished() {
+		finishedAt := j.finishedAt
+		info.FinishedAt = &finishedAt
+		info.DurationMs = j.finishedAt.Sub(j.startedAt).Milliseconds()
+	} else {
+		info.DurationMs = time.Since(j.startedAt).Milliseconds()
+	}
+
+	return info
+}
+
+// output returns the output captured so far
+func (j *job) output() Output {
+	return Output{
+		ID:              j.id,
+		Stdout:          j.stdout.String(),
+		Stderr:          j.stderr.String(),
+		StdoutTruncated: j.stdout.Truncated(),
+		StderrTruncated: j.stderr.Truncated(),
+	}
+}
+
+// finished reports whether the job has reached a terminal status
+func (j *job) finished() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.status.Finished()
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxLogLines is the number of output lines kept per job for streaming
+	MaxLogLines = 10000
+	// maxLineBytes splits lines longer than this into several entries
+	maxLineBytes = 64 << 10
+)
+
+// Line is a single line of job output
+type Line struct {
+	// Seq is the sequence number of the line, starting at 1
+	Seq    uint64    `json:"seq"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// lineLog stores job output line by line so clients can follow it and
+// resume from the last sequence number they saw
+type lineLog struct {
+	mu      sync.Mutex
+	lines   []Line
+	seq     uint64
+	closed  bool
+	notify  chan struct{}
+	pending map[string][]byte
+}
+
+// newLineLog creates an empty lineLog
+func newLineLog() *lineLog {
+	return &lineLog{
+		notify:  make(chan struct{}),
+		pending: make(map[string][]byte),
+	}
+}
+
+// writer returns an io.Writer that appends to the log under stream
+func (l *lineLog) writer(stream string) *lineWriter {
+	return &lineWriter{log: l, stream: stream}
+}
+
+// write splits p into lines, buffering any trailing partial line
+func (l *lineLog) write(stream string, p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	buf := append(l.pending[stream], p...)
+	appended := false
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			if len(buf) < maxLineBytes {
+				break
+			}
+			i = maxLineBytes
+			l.appendLocked(stream, buf[:i])
+			buf = buf[i:]
+		} else {
+			l.appendLocked(stream, buf[:i])
+			buf = buf[i+1:]
+		}
+		appended = true
+	}
+	l.pending[stream] = append([]byte(nil), buf...)
+
+	if appended {
+		l.wakeLocked()
+	}
+}
+
+// close flushes partial lines and marks the log as complete
+func (l *lineLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, stream := range []string{"stdout", "stderr"} {
+		if len(l.pending[stream]) > 0 {
+			l.appendLocked(stream, l.pending[stream])
+		}
+	}
+	l.pending = nil
+	l.closed = true
+	l.wakeLocked()
+}
+
+// since returns the lines with a sequence number greater than after, a
+// channel that is closed when more lines arrive, and whether the log is
+// complete
+func (l *lineLog) since(after uint64) ([]Line, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lines []Line
+	if len(l.lines) > 0 {
+		first := l.lines[0].Seq
+		start := 0
+		if after >= first {
+			start = int(after - first + 1)
+		}
+		if start < len(l.lines) {
+			lines = append(lines, l.lines[start:]...)
+		}
+	}
+
+	return lines, l.notify, l.closed
+}
+
+// appendLocked adds a line, dropping the oldest once MaxLogLines is reached.
+// l.mu must be held.
+func (l *lineLog) appendLocked(stream string, text []byte) {
+	l.seq++
+	l.lines = append(l.lines, Line{
+		Seq:    l.seq,
+		Stream: stream,
+		Text:   string(bytes.TrimSuffix(text, []byte("\r"))),
+		Time:   time.Now().UTC(),
+	})
+	if len(l.lines) > MaxLogLines {
+		l.lines = append(l.lines[:0], l.lines[len(l.lines)-MaxLogLines:]...)
+	}
+}
+
+// wakeLocked notifies waiting followers. l.mu must be held.
+func (l *lineLog) wakeLocked() {
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// lineWriter is an io.Writer for one output stream of a lineLog
+type lineWriter struct {
+	log    *lineLog
+	stream string
+}
+
+// Write implements io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.log.write(w.stream, p)
+	return len(p), nil
+}
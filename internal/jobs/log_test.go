@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineLogSplitsLines(t *testing.T) {
+	l := newLineLog()
+	stdout := l.writer("stdout")
+	stderr := l.writer("stderr")
+
+	_, err := stdout.Write([]byte("first\nsec"))
+	require.NoError(t, err)
+	_, err = stderr.Write([]byte("warning\r\n"))
+	require.NoError(t, err)
+	_, err = stdout.Write([]byte("ond\npartial"))
+	require.NoError(t, err)
+
+	lines, _, done := l.since(0)
+	require.False(t, done)
+	require.Len(t, lines, 3)
+	require.Equal(t, Line{Seq: 1, Stream: "stdout", Text: "first", Time: lines[0].Time}, lines[0])
+	require.Equal(t, "stderr", lines[1].Stream)
+	require.Equal(t, "warning", lines[1].Text)
+	require.Equal(t, "second", lines[2].Text)
+
+	l.close()
+	lines, _, done = l.since(3)
+	require.True(t, done)
+	require.Len(t, lines, 1)
+	require.Equal(t, uint64(4), lines[0].Seq)
+	require.Equal(t, "partial", lines[0].Text)
+}
+
+func TestLineLogResume(t *testing.T) {
+	l := newLineLog()
+	_, err := l.writer("stdout").Write([]byte("a\nb\nc\n"))
+	require.NoError(t, err)
+
+	lines, _, _ := l.since(2)
+	require.Len(t, lines, 1)
+	require.Equal(t, "c", lines[0].Text)
+
+	lines, _, _ = l.since(3)
+	require.Empty(t, lines)
+}
+
+func TestLineLogNotifies(t *testing.T) {
+	l := newLineLog()
+	_, wait, _ := l.since(0)
+
+	select {
+	case <-wait:
+		t.Fatal("expected no notification before a line is written")
+	default:
+	}
+
+	_, err := l.writer("stdout").Write([]byte("line\n"))
+	require.NoError(t, err)
+
+	select {
+	case <-wait:
+	default:
+		t.Fatal("expected notification after a line is written")
+	}
+}
+
+func TestLineLogBounded(t *testing.T) {
+	l := newLineLog()
+	w := l.writer("stdout")
+	for i := 0; i < MaxLogLines+5; i++ {
+		_, err := w.Write([]byte("x\n"))
+		require.NoError(t, err)
+	}
+
+	lines, _, _ := l.since(0)
+	require.Len(t, lines, MaxLogLines)
+	require.Equal(t, uint64(6), lines[0].Seq)
+	require.Equal(t, uint64(MaxLogLines+5), lines[len(lines)-1].Seq)
+}
+
+func TestLineLogLongLine(t *testing.T) {
+	l := newLineLog()
+	_, err := l.writer("stdout").Write([]byte(strings.Repeat("x", maxLineBytes+10)))
+	require.NoError(t, err)
+
+	lines, _, _ := l.since(0)
+	require.Len(t, lines, 1)
+	require.Len(t, lines[0].Text, maxLineBytes)
+}
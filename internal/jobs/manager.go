@@ -0,0 +1,268 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/commands"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// DefaultMaxJobs is the default number of jobs kept in the job table
+const DefaultMaxJobs = 100
+
+var (
+	// ErrNotFound is returned when a job ID is unknown or has been evicted
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when canceling a job that has already finished
+	ErrFinished = errors.New("job already finished")
+	// ErrTooManyJobs is returned when the job table is full of running jobs
+	ErrTooManyJobs = errors.New("too many running jobs")
+	// ErrClosed is returned when submitting a job after the manager is closed
+	ErrClosed = errors.New("job manager closed")
+)
+
+// ManagerConfig holds the configuration for the job manager
+type ManagerConfig struct {
+	Executor commands.Executor
+	// MaxJobs bounds the job table; the oldest finished jobs are evicted
+	// to make room for new ones
+	MaxJobs int
+	// OnFinish is called once for every job that reaches a terminal status
+	OnFinish func(Info)
+}
+
+// Manager runs commands in the background and tracks their state
+type Manager struct {
+	executor commands.Executor
+	maxJobs  int
+	onFinish func(Info)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	jobs   map[string]*job
+	order  []string // job IDs in submission order
+	closed bool
+}
+
+// NewManager creates a new job manager
+func NewManager(cfg ManagerConfig) *Manager {
+	if cfg.MaxJobs <= 0 {
+		cfg.MaxJobs = DefaultMaxJobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		executor: cfg.Executor,
+		maxJobs:  cfg.MaxJobs,
+		onFinish: cfg.OnFinish,
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Submit validates spec and starts it in the background
+func (m *Manager) Submit(spec commands.Spec) (Info, error) {
+	if err := spec.Validate(); err != nil {
+		return Info{}, err
+	}
+	timeout, _ := spec.TimeoutDuration()
+
+	id, err := newID()
+	if err != nil {
+		return Info{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
+	j := &job{
+		id:        id,
+		spec:      spec,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		stdout:    commands.NewOutputBuffer(commands.MaxOutputBytes),
+		stderr:    commands.NewOutputBuffer(commands.MaxOutputBytes),
+		log:       newLineLog(),
+		status:    StatusRunning,
+		startedAt: time.Now(),
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		cancel()
+		return Info{}, ErrClosed
+	}
+	if err := m.makeRoomLocked(); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return Info{}, err
+	}
+	m.jobs[id] = j
+	m.order = append(m.order, id)
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go m.run(ctx, j)
+
+	logging.Info().Str("job_id", id).Msg("Job started")
+	return j.info(), nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (Info, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Info{}, err
+	}
+	return j.info(), nil
+}
+
+// Output returns the output captured so far for the job with the given ID
+func (m *Manager) Output(id string) (Output, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Output{}, err
+	}
+	return j.output(), nil
+}
+
+// Lines returns the output lines of the job with a sequence number greater
+// than after. The returned channel is closed when new lines are available
+// and done reports whether the job has finished and all output was returned.
+func (m *Manager) Lines(id string, after uint64) (lines []Line, wait <-chan struct{}, done bool, err error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	lines, wait, done = j.log.since(after)
+	return lines, wait, done, nil
+}
+
+// Cancel stops the job with the given ID and waits for it to exit
+func (m *Manager) Cancel(id string) (Info, error) {
+	j, err := m.lookup(id)
+	if err != nil {
+		return Info{}, err
+	}
+
+	j.mu.Lock()
+	if j.status.Finished() {
+		j.mu.Unlock()
+		return j.info(), ErrFinished
+	}
+	j.canceled = true
+	j.mu.Unlock()
+
+	j.cancel()
+	<-j.done
+
+	return j.info(), nil
+}
+
+// Close cancels all running jobs and waits for them to exit. Jobs submitted
+// after Close are rejected with ErrClosed.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run executes the job and records its final status
+func (m *Manager) run(ctx context.Context, j *job) {
+	defer m.wg.Done()
+	defer close(j.done)
+	defer j.cancel()
+
+	stdout := io.MultiWriter(j.stdout, j.log.writer("stdout"))
+	stderr := io.MultiWriter(j.stderr, j.log.writer("stderr"))
+	exitCode, err := m.executor.Execute(ctx, j.spec, stdout, stderr)
+
+	j.mu.Lock()
+	j.finishedAt = time.Now()
+	switch {
+	case err != nil:
+		j.status = StatusFailed
+		j.err = err.Error()
+	case j.canceled || errors.Is(ctx.Err(), context.Canceled):
+		j.status = StatusCanceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		j.status = StatusTimedOut
+	case exitCode == 0:
+		j.status = StatusSucceeded
+	default:
+		j.status = StatusFailed
+	}
+	if err == nil {
+		j.exitCode = &exitCode
+	}
+	j.mu.Unlock()
+
+	// Close the log only after the final status is set so that followers
+	// woken by it observe the finished job
+	j.log.close()
+
+	info := j.info()
+	logging.Info().
+		Str("job_id", info.ID).
+		Str("status", string(info.Status)).
+		Int64("duration_ms", info.DurationMs).
+		Msg("Job finished")
+
+	if m.onFinish != nil {
+		m.onFinish(info)
+	}
+}
+
+// lookup returns the job with the given ID
+func (m *Manager) lookup(id string) (*job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+// makeRoomLocked evicts the oldest finished jobs until a new job fits.
+// m.mu must be held.
+func (m *Manager) makeRoomLocked() error {
+	for len(m.jobs) >= m.maxJobs {
+		evicted := false
+		for i, id := range m.order {
+			if m.jobs[id].finished() {
+				delete(m.jobs, id)
+				m.order = append(m.order[:i], m.order[i+1:]...)
+				evicted = true
+				break
+			}
+		}
+		if !evicted {
+			return fmt.Errorf("%w: limit is %d", ErrTooManyJobs, m.maxJobs)
+		}
+	}
+	return nil
+}
+
+// newID generates a random job ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/commands"
+)
+
+// waitFinished polls until the job reaches a terminal status
+func waitFinished(t *testing.T, m *Manager, id string) Info {
+	t.Helper()
+
+	var info Info
+	require.Eventually(t, func() bool {
+		var err error
+		info, err = m.Get(id)
+		require.NoError(t, err)
+		return info.Status.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return info
+}
+
+func TestSubmit(t *testing.T) {
+	finished := make(chan Info, 1)
+	m := NewManager(ManagerConfig{
+		Executor: &commands.FakeExecutor{Stdout: "out", Stderr: "err"},
+		OnFinish: func(info Info) { finished <- info },
+	})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "true"})
+	require.NoError(t, err)
+	require.NotEmpty(t, info.ID)
+
+	info = waitFinished(t, m, info.ID)
+	require.Equal(t, StatusSucceeded, info.Status)
+	require.NotNil(t, info.ExitCode)
+	require.Equal(t, 0, *info.ExitCode)
+	require.NotNil(t, info.FinishedAt)
+
+	output, err := m.Output(info.ID)
+	require.NoError(t, err)
+	require.Equal(t, "out", output.Stdout)
+	require.Equal(t, "err", output.Stderr)
+
+	require.Equal(t, info.ID, (<-finished).ID)
+}
+
+func TestSubmitInvalidSpec(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{}})
+	defer m.Close()
+
+	_, err := m.Submit(commands.Spec{})
+	require.ErrorIs(t, err, commands.ErrInvalidSpec)
+}
+
+func TestFailedJob(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{ExitCode: 7}})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "false"})
+	require.NoError(t, err)
+
+	info = waitFinished(t, m, info.ID)
+	require.Equal(t, StatusFailed, info.Status)
+	require.Equal(t, 7, *info.ExitCode)
+}
+
+func TestCancel(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{Block: true}})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "sleep 600"})
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, info.Status)
+
+	info, err = m.Cancel(info.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusCanceled, info.Status)
+
+	_, err = m.Cancel(info.ID)
+	require.ErrorIs(t, err, ErrFinished)
+
+	_, err = m.Cancel("unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTimeout(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{Block: true}})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "sleep 600", Timeout: "50ms"})
+	require.NoError(t, err)
+
+	info = waitFinished(t, m, info.ID)
+	require.Equal(t, StatusTimedOut, info.Status)
+}
+
+func TestEviction(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{}, MaxJobs: 2})
+	defer m.Close()
+
+	first, err := m.Submit(commands.Spec{Script: "true"})
+	require.NoError(t, err)
+	waitFinished(t, m, first.ID)
+
+	second, err := m.Submit(commands.Spec{Script: "true"})
+	require.NoError(t, err)
+	waitFinished(t, m, second.ID)
+
+	third, err := m.Submit(commands.Spec{Script: "true"})
+	require.NoError(t, err)
+
+	_, err = m.Get(first.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Get(second.ID)
+	require.NoError(t, err)
+	_, err = m.Get(third.ID)
+	require.NoError(t, err)
+}
+
+func TestTooManyRunningJobs(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{Block: true}, MaxJobs: 1})
+	defer m.Close()
+
+	_, err := m.Submit(commands.Spec{Script: "sleep 600"})
+	require.NoError(t, err)
+
+	_, err = m.Submit(commands.Spec{Script: "sleep 600"})
+	require.ErrorIs(t, err, ErrTooManyJobs)
+}
+
+func TestClose(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{Block: true}})
+
+	info, err := m.Submit(commands.Spec{Script: "sleep 600"})
+	require.NoError(t, err)
+
+	m.Close()
+
+	info, err = m.Get(info.ID)
+	require.NoError(t, err)
+	require.Equal(t, StatusCanceled, info.Status)
+}
+
+func TestSubmitAfterClose(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{}})
+	m.Close()
+
+	_, err := m.Submit(commands.Spec{Script: "true"})
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestInfoOmitsEnvValues(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{}})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "true", Env: map[string]string{"TOKEN": "secret", "A": "1"}})
+	require.NoError(t, err)
+	require.Nil(t, info.Spec.Env)
+	require.Equal(t, []string{"A", "TOKEN"}, info.EnvNames)
+
+	body, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "secret")
+}
+
+func TestLines(t *testing.T) {
+	m := NewManager(ManagerConfig{Executor: &commands.FakeExecutor{Stdout: "one\ntwo\n", Stderr: "err\n"}})
+	defer m.Close()
+
+	info, err := m.Submit(commands.Spec{Script: "true"})
+	require.NoError(t, err)
+	waitFinished(t, m, info.ID)
+
+	lines, _, done, err := m.Lines(info.ID, 0)
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Len(t, lines, 3)
+	require.Equal(t, "one", lines[0].Text)
+	require.Equal(t, "two", lines[1].Text)
+	require.Equal(t, "stderr", lines[2].Stream)
+
+	_, _, _, err = m.Lines("unknown", 0)
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -154,3 +154,8 @@ func Fatal() *zerolog.Event {
 func With() zerolog.Context {
 	return defaultLogger.With()
 }
+
+// SetLevel changes the minimum level of the global logger
+func SetLevel(level string) {
+	zerolog.SetGlobalLevel(parseLevel(level))
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Batch is the JSON body of a POST /metrics request to the API. It holds
+// every sample gathered in one collection interval.
+type Batch struct {
+	// Host is the hostname of the agent
+	Host string `json:"host"`
+	// Timestamp is when the samples were gathered
+	Timestamp time.Time `json:"timestamp"`
+	Samples   []Sample  `json:"samples"`
+}
+
+// Sample is a single value of a time series. Histograms and summaries are
+// flattened the way the Prometheus text format does, into _bucket (with an
+// le label) or quantile samples plus _sum and _count samples.
+type Sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	// TimestampMs is the sample time in milliseconds since the Unix epoch
+	TimestampMs int64 `json:"timestamp_ms"`
+}
+
+// NewBatch converts gathered metric families into a Batch. Samples without
+// an explicit timestamp are stamped with now. NaN and infinite values, which
+// JSON cannot represent, are skipped.
+func NewBatch(host string, families []*dto.MetricFamily, now time.Time) *Batch {
+	b := &Batch{
+		Host:      host,
+		Timestamp: now.UTC(),
+		Samples:   []Sample{},
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			b.addMetric(family.GetName(), family.GetType(), m, now)
+		}
+	}
+	return b
+}
+
+// addMetric appends the samples of m, a metric of family name and type typ
+func (b *Batch) addMetric(name string, typ dto.MetricType, m *dto.Metric, now time.Time) {
+	ts := now.UnixMilli()
+	if m.TimestampMs != nil {
+		ts = m.GetTimestampMs()
+	}
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+
+	add := func(name string, value float64, extra ...string) {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+		sampleLabels := labels
+		if len(extra) > 0 {
+			sampleLabels = make(map[string]string, len(labels)+1)
+			for k, v := range labels {
+				sampleLabels[k] = v
+			}
+			sampleLabels[extra[0]] = extra[1]
+		}
+		if len(sampleLabels) == 0 {
+			sampleLabels = nil
+		}
+		b.Samples = append(b.Samples, Sample{Name: name, Labels: sampleLabels, Value: value, TimestampMs: ts})
+	}
+
+	switch typ {
+	case dto.MetricType_COUNTER:
+		add(name, m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		add(name, m.GetGauge().GetValue())
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		for _, q := range s.GetQuantile() {
+			add(name, q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+		}
+		add(name+"_sum", s.GetSampleSum())
+		add(name+"_count", float64(s.GetSampleCount()))
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		h := m.GetHistogram()
+		hasInf := false
+		for _, bucket := range h.GetBucket() {
+			hasInf = hasInf || math.IsInf(bucket.GetUpperBound(), 1)
+			add(name+"_bucket", float64(bucket.GetCumulativeCount()), "le", formatFloat(bucket.GetUpperBound()))
+		}
+		if !hasInf {
+			add(name+"_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+		}
+		add(name+"_sum", h.GetSampleSum())
+		add(name+"_count", float64(h.GetSampleCount()))
+	default:
+		add(name, m.GetUntyped().GetValue())
+	}
+}
+
+// formatFloat formats a bucket bound or quantile like the Prometheus text format
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBatch(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_requests_total", Help: "Requests"})
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_temperature", Help: "Temperature"}, []string{"sensor"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_latency_seconds", Help: "Latency", Buckets: []float64{0.1, 1}})
+	nan := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_nan", Help: "Not a number"})
+	registry.MustRegister(counter, gauge, histogram, nan)
+
+	counter.Add(3)
+	gauge.WithLabelValues("cpu").Set(42.5)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	nan.Set(math.NaN())
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	batch := NewBatch("agent-1", families, now)
+	require.Equal(t, "agent-1", batch.Host)
+	require.Equal(t, now.UTC(), batch.Timestamp)
+
+	ts := now.UnixMilli()
+	require.ElementsMatch(t, []Sample{
+		{Name: "test_requests_total", Value: 3, TimestampMs: ts},
+		{Name: "test_temperature", Labels: map[string]string{"sensor": "cpu"}, Value: 42.5, TimestampMs: ts},
+		{Name: "test_latency_seconds_bucket", Labels: map[string]string{"le": "0.1"}, Value: 1, TimestampMs: ts},
+		{Name: "test_latency_seconds_bucket", Labels: map[string]string{"le": "1"}, Value: 2, TimestampMs: ts},
+		{Name: "test_latency_seconds_bucket", Labels: map[string]string{"le": "+Inf"}, Value: 2, TimestampMs: ts},
+		{Name: "test_latency_seconds_sum", Value: 0.55, TimestampMs: ts},
+		{Name: "test_latency_seconds_count", Value: 2, TimestampMs: ts},
+	}, batch.Samples)
+}
+
+func TestNewBatchEmpty(t *testing.T) {
+	batch := NewBatch("agent-1", nil, time.Now())
+	require.NotNil(t, batch.Samples)
+	require.Empty(t, batch.Samples)
+}
@@ -1,25 +1,31 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
 )
 
-// SystemMetrics represents the collected system metrics
+// SystemMetrics represents the collected system metrics. Sections that
+// were not collected are nil.
 type SystemMetrics struct {
-	Timestamp time.Time     `json:"timestamp"`
-	CPU       CPUMetrics    `json:"cpu"`
-	Memory    MemoryMetrics `json:"memory"`
-	Disk      DiskMetrics   `json:"disk"`
-	Network   NetMetrics    `json:"network"`
-	HostInfo  HostInfo      `json:"host_info"`
+	Timestamp time.Time      `json:"timestamp"`
+	CPU       *CPUMetrics    `json:"cpu,omitempty"`
+	Memory    *MemoryMetrics `json:"memory,omitempty"`
+	Disk      *DiskMetrics   `json:"disk,omitempty"`
+	Network   *NetMetrics    `json:"network,omitempty"`
+	HostInfo  *HostInfo      `json:"host_info,omitempty"`
 }
 
 // CPUMetrics represents CPU-related metrics
@@ -38,13 +44,24 @@ type MemoryMetrics struct {
 
 // DiskMetrics represents disk-related metrics
 type DiskMetrics struct {
-	Total       uint64                         `json:"total"`
-	Used        uint64                         `json:"used"`
-	Free        uint64                         `json:"free"`
-	UsedPercent float64                        `json:"used_percent"`
+	Filesystems []FilesystemMetrics            `json:"filesystems"`
 	IOCounters  map[string]disk.IOCountersStat `json:"io_counters"`
 }
 
+// FilesystemMetrics represents the usage of a mounted filesystem
+type FilesystemMetrics struct {
+	Device      string  `json:"device"`
+	Mountpoint  string  `json:"mountpoint"`
+	Fstype      string  `json:"fstype"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Free        uint64  `json:"free"`
+	UsedPercent float64 `json:"used_percent"`
+	InodesTotal uint64  `json:"inodes_total"`
+	InodesUsed  uint64  `json:"inodes_used"`
+	InodesFree  uint64  `json:"inodes_free"`
+}
+
 // NetMetrics represents network-related metrics
 type NetMetrics struct {
 	Interfaces []string                      `json:"interfaces"`
@@ -57,41 +74,106 @@ type HostInfo struct {
 	OS       string `json:"os"`
 	Platform string `json:"platform"`
 	Uptime   uint64 `json:"uptime"`
+	// BootTime is when the host booted, in seconds since the Unix epoch
+	BootTime uint64 `json:"boot_time"`
+}
+
+// defaultInterval is the sampling interval used when none is set
+const defaultInterval = 15 * time.Second
+
+// snapshot is a sample of the system metrics
+type snapshot struct {
+	metrics []prometheus.Metric
+	// system holds every section of the sample. CPU is nil until a
+	// previous sample exists to compute the usage against, and sections
+	// that could not be read are nil with the reason in errs.
+	system SystemMetrics
+	errs   map[string]error
+	time   time.Time
+}
+
+// fail records that section could not be read
+func (s *snapshot) fail(section string, err error) {
+	if s.errs == nil {
+		s.errs = make(map[string]error)
+	}
+	s.errs[section] = err
 }
 
 // Collector implements prometheus.Collector interface
 type Collector struct {
-	interval time.Duration
+	interval   atomic.Int64
+	diskFilter atomic.Pointer[DiskFilter]
+
+	// snapshot is the latest sample taken by Start
+	snapshot atomic.Pointer[snapshot]
+	running  atomic.Bool
+
+	// sampleMu serializes samples and guards the CPU times of the previous
+	// sample taken by Start, which CPU usage is computed against. CPU usage
+	// is not reported until Start has taken a sample.
+	sampleMu   sync.Mutex
+	prevCPU    *cpu.TimesStat
+	prevPerCPU []cpu.TimesStat
 
 	// CPU metrics
 	cpuUsage   *prometheus.Desc
 	cpuPerCore *prometheus.Desc
+	cpuTime    *prometheus.Desc
+	load1      *prometheus.Desc
+	load5      *prometheus.Desc
+	load15     *prometheus.Desc
 
 	// Memory metrics
-	memoryTotal   *prometheus.Desc
-	memoryUsed    *prometheus.Desc
-	memoryFree    *prometheus.Desc
-	memoryPercent *prometheus.Desc
+	memoryTotal     *prometheus.Desc
+	memoryUsed      *prometheus.Desc
+	memoryFree      *prometheus.Desc
+	memoryPercent   *prometheus.Desc
+	memoryAvailable *prometheus.Desc
+	memoryBuffers   *prometheus.Desc
+	memoryCached    *prometheus.Desc
+	memoryDirty     *prometheus.Desc
+	memorySlab      *prometheus.Desc
+
+	// Swap metrics
+	swapTotal *prometheus.Desc
+	swapUsed  *prometheus.Desc
+	swapIn    *prometheus.Desc
+	swapOut   *prometheus.Desc
 
 	// Disk metrics
-	diskTotal   *prometheus.Desc
-	diskUsed    *prometheus.Desc
-	diskFree    *prometheus.Desc
-	diskPercent *prometheus.Desc
-	diskIO      *prometheus.Desc
+	diskTotal       *prometheus.Desc
+	diskUsed        *prometheus.Desc
+	diskFree        *prometheus.Desc
+	diskPercent     *prometheus.Desc
+	diskInodesTotal *prometheus.Desc
+	diskInodesUsed  *prometheus.Desc
+	diskInodesFree  *prometheus.Desc
+	diskIO          *prometheus.Desc
+	diskIOOps       *prometheus.Desc
+	diskIOMerged    *prometheus.Desc
+	diskIOTime      *prometheus.Desc
+	diskIOBusy      *prometheus.Desc
+	diskIOWeighted  *prometheus.Desc
+	diskIOInFlight  *prometheus.Desc
 
 	// Network metrics
-	networkIO *prometheus.Desc
+	networkIO      *prometheus.Desc
+	networkPackets *prometheus.Desc
+	networkErrors  *prometheus.Desc
+	networkDrops   *prometheus.Desc
 
 	// Host metrics
 	hostUptime *prometheus.Desc
+
+	// Sampler metrics
+	lastSample *prometheus.Desc
 }
 
 // NewCollector creates a new metrics collector
 func NewCollector(interval time.Duration) *Collector {
-	return &Collector{
-		interval: interval,
-
+	diskLabels := []string{"device", "mountpoint", "fstype"}
+	c := &Collector{
 		// CPU metrics
 		cpuUsage: prometheus.NewDesc(
 			"system_cpu_usage_percent",
@@ -103,6 +185,26 @@ func NewCollector(interval time.Duration) *Collector {
 			"CPU usage percentage per core",
 			[]string{"core"}, nil,
 		),
+		cpuTime: prometheus.NewDesc(
+			"system_cpu_time_seconds_total",
+			"Time all CPUs spent in each mode in seconds",
+			[]string{"mode"}, nil,
+		),
+		load1: prometheus.NewDesc(
+			"system_load1",
+			"1 minute load average",
+			nil, nil,
+		),
+		load5: prometheus.NewDesc(
+			"system_load5",
+			"5 minute load average",
+			nil, nil,
+		),
+		load15: prometheus.NewDesc(
+			"system_load15",
+			"15 minute load average",
+			nil, nil,
+		),
 
 		// Memory metrics
 		memoryTotal: prometheus.NewDesc(
@@ -125,40 +227,147 @@ func NewCollector(interval time.Duration) *Collector {
 			"Memory usage percentage",
 			nil, nil,
 		),
+		memoryAvailable: prometheus.NewDesc(
+			"system_memory_available_bytes",
+			"Memory available for new processes without swapping in bytes",
+			nil, nil,
+		),
+		memoryBuffers: prometheus.NewDesc(
+			"system_memory_buffers_bytes",
+			"Memory used by kernel buffers in bytes",
+			nil, nil,
+		),
+		memoryCached: prometheus.NewDesc(
+			"system_memory_cached_bytes",
+			"Memory used by the page cache in bytes",
+			nil, nil,
+		),
+		memoryDirty: prometheus.NewDesc(
+			"system_memory_dirty_bytes",
+			"Memory waiting to be written back to disk in bytes",
+			nil, nil,
+		),
+		memorySlab: prometheus.NewDesc(
+			"system_memory_slab_bytes",
+			"Memory used by kernel slab allocations in bytes",
+			nil, nil,
+		),
+
+		// Swap metrics
+		swapTotal: prometheus.NewDesc(
+			"system_swap_total_bytes",
+			"Total swap space in bytes",
+			nil, nil,
+		),
+		swapUsed: prometheus.NewDesc(
+			"system_swap_used_bytes",
+			"Used swap space in bytes",
+			nil, nil,
+		),
+		swapIn: prometheus.NewDesc(
+			"system_swap_in_bytes_total",
+			"Data swapped in from disk in bytes",
+			nil, nil,
+		),
+		swapOut: prometheus.NewDesc(
+			"system_swap_out_bytes_total",
+			"Data swapped out to disk in bytes",
+			nil, nil,
+		),
 
 		// Disk metrics
 		diskTotal: prometheus.NewDesc(
 			"system_disk_total_bytes",
 			"Total disk space in bytes",
-			nil, nil,
+			diskLabels, nil,
 		),
 		diskUsed: prometheus.NewDesc(
 			"system_disk_used_bytes",
 			"Used disk space in bytes",
-			nil, nil,
+			diskLabels, nil,
 		),
 		diskFree: prometheus.NewDesc(
 			"system_disk_free_bytes",
 			"Free disk space in bytes",
-			nil, nil,
+			diskLabels, nil,
 		),
 		diskPercent: prometheus.NewDesc(
 			"system_disk_usage_percent",
 			"Disk usage percentage",
-			nil, nil,
+			diskLabels, nil,
+		),
+		diskInodesTotal: prometheus.NewDesc(
+			"system_disk_inodes",
+			"Total number of inodes",
+			diskLabels, nil,
+		),
+		diskInodesUsed: prometheus.NewDesc(
+			"system_disk_inodes_used",
+			"Number of used inodes",
+			diskLabels, nil,
+		),
+		diskInodesFree: prometheus.NewDesc(
+			"system_disk_inodes_free",
+			"Number of free inodes",
+			diskLabels, nil,
 		),
 		diskIO: prometheus.NewDesc(
-			"system_disk_io_bytes",
+			"system_disk_io_bytes_total",
 			"Disk I/O in bytes",
 			[]string{"device", "type"}, nil,
 		),
+		diskIOOps: prometheus.NewDesc(
+			"system_disk_io_operations_total",
+			"Completed disk I/O operations",
+			[]string{"device", "type"}, nil,
+		),
+		diskIOMerged: prometheus.NewDesc(
+			"system_disk_io_merged_operations_total",
+			"Adjacent disk I/O operations merged into one",
+			[]string{"device", "type"}, nil,
+		),
+		diskIOTime: prometheus.NewDesc(
+			"system_disk_io_time_seconds_total",
+			"Time spent on disk I/O operations in seconds",
+			[]string{"device", "type"}, nil,
+		),
+		diskIOBusy: prometheus.NewDesc(
+			"system_disk_io_busy_seconds_total",
+			"Time the disk spent with I/O operations in progress in seconds",
+			[]string{"device"}, nil,
+		),
+		diskIOWeighted: prometheus.NewDesc(
+			"system_disk_io_weighted_seconds_total",
+			"Time spent on disk I/O weighted by the number of operations in progress, in seconds",
+			[]string{"device"}, nil,
+		),
+		diskIOInFlight: prometheus.NewDesc(
+			"system_disk_io_in_progress",
+			"Disk I/O operations currently in progress",
+			[]string{"device"}, nil,
+		),
 
 		// Network metrics
 		networkIO: prometheus.NewDesc(
-			"system_network_io_bytes",
+			"system_network_io_bytes_total",
 			"Network I/O in bytes",
 			[]string{"interface", "direction"}, nil,
 		),
+		networkPackets: prometheus.NewDesc(
+			"system_network_io_packets_total",
+			"Network packets",
+			[]string{"interface", "direction"}, nil,
+		),
+		networkErrors: prometheus.NewDesc(
+			"system_network_io_errors_total",
+			"Network errors",
+			[]string{"interface", "direction"}, nil,
+		),
+		networkDrops: prometheus.NewDesc(
+			"system_network_io_drops_total",
+			"Dropped network packets",
+			[]string{"interface", "direction"}, nil,
+		),
 
 		// Host metrics
 		hostUptime: prometheus.NewDesc(
@@ -166,143 +375,358 @@ func NewCollector(interval time.Duration) *Collector {
 			"System uptime in seconds",
 			nil, nil,
 		),
+
+		// Sampler metrics
+		lastSample: prometheus.NewDesc(
+			"system_metrics_last_sample_timestamp_seconds",
+			"Unix time the system metrics were last sampled",
+			nil, nil,
+		),
 	}
+	c.SetInterval(interval)
+	c.SetDiskFilter(&DiskFilter{})
+	return c
+}
+
+// Interval returns the metrics collection interval
+func (c *Collector) Interval() time.Duration {
+	return time.Duration(c.interval.Load())
+}
+
+// SetInterval changes the metrics collection interval
+func (c *Collector) SetInterval(interval time.Duration) {
+	c.interval.Store(int64(interval))
+}
+
+// SetDiskFilter changes the filesystems reported by the disk metrics
+func (c *Collector) SetDiskFilter(filter *DiskFilter) {
+	c.diskFilter.Store(filter)
 }
 
 // Describe implements prometheus.Collector
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.cpuUsage
 	ch <- c.cpuPerCore
+	ch <- c.cpuTime
+	ch <- c.load1
+	ch <- c.load5
+	ch <- c.load15
 	ch <- c.memoryTotal
 	ch <- c.memoryUsed
 	ch <- c.memoryFree
 	ch <- c.memoryPercent
+	ch <- c.memoryAvailable
+	ch <- c.memoryBuffers
+	ch <- c.memoryCached
+	ch <- c.memoryDirty
+	ch <- c.memorySlab
+	ch <- c.swapTotal
+	ch <- c.swapUsed
+	ch <- c.swapIn
+	ch <- c.swapOut
 	ch <- c.diskTotal
 	ch <- c.diskUsed
 	ch <- c.diskFree
 	ch <- c.diskPercent
+	ch <- c.diskInodesTotal
+	ch <- c.diskInodesUsed
+	ch <- c.diskInodesFree
 	ch <- c.diskIO
+	ch <- c.diskIOOps
+	ch <- c.diskIOMerged
+	ch <- c.diskIOTime
+	ch <- c.diskIOBusy
+	ch <- c.diskIOWeighted
+	ch <- c.diskIOInFlight
 	ch <- c.networkIO
+	ch <- c.networkPackets
+	ch <- c.networkErrors
+	ch <- c.networkDrops
 	ch <- c.hostUptime
+	ch <- c.lastSample
+}
+
+// Start samples the system metrics every Interval(), re-read after each
+// sample, until ctx is done. While it runs scrapes are served from the
+// latest sample instead of querying the system. The first sample is taken
+// right away but only reports CPU usage once a full interval has elapsed.
+func (c *Collector) Start(ctx context.Context) error {
+	c.snapshot.Store(c.sample(true))
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	timer := time.NewTimer(c.nextInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			c.snapshot.Store(c.sample(true))
+			timer.Reset(c.nextInterval())
+		}
+	}
+}
+
+// nextInterval returns the time until the next sample
+func (c *Collector) nextInterval() time.Duration {
+	if interval := c.Interval(); interval > 0 {
+		return interval
+	}
+	return defaultInterval
 }
 
-// Collect implements prometheus.Collector
+// Collect implements prometheus.Collector. It serves the latest sample
+// taken by Start, or samples the system directly if Start is not running.
 func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	// Collect CPU metrics
-	if percent, err := cpu.Percent(0, false); err == nil && len(percent) > 0 {
-		ch <- prometheus.MustNewConstMetric(
-			c.cpuUsage,
-			prometheus.GaugeValue,
-			percent[0],
-		)
+	s := c.latest()
+	for _, m := range s.metrics {
+		ch <- m
+	}
+	ch <- prometheus.MustNewConstMetric(
+		c.lastSample,
+		prometheus.GaugeValue,
+		float64(s.time.UnixNano())/float64(time.Second),
+	)
+}
+
+// latest returns the latest sample taken by Start, or samples the system
+// if Start is not running. Such samples do not advance the CPU times that
+// Start computes CPU usage against.
+func (c *Collector) latest() *snapshot {
+	if c.running.Load() {
+		if s := c.snapshot.Load(); s != nil {
+			return s
+		}
+	}
+	return c.sample(false)
+}
+
+// sample queries the system metrics. CPU usage is reported since the
+// previous sample taken by Start, if any, and the CPU times are recorded
+// for the next sample when advance is true.
+func (c *Collector) sample(advance bool) *snapshot {
+	c.sampleMu.Lock()
+	defer c.sampleMu.Unlock()
+
+	s := &snapshot{time: time.Now()}
+	s.system.Timestamp = s.time.UTC()
+	add := func(desc *prometheus.Desc, value float64, labels ...string) {
+		s.metrics = append(s.metrics, prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...))
+	}
+	addCounter := func(desc *prometheus.Desc, value float64, labels ...string) {
+		s.metrics = append(s.metrics, prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...))
 	}
 
-	if perCPU, err := cpu.Percent(0, true); err == nil {
-		for i, usage := range perCPU {
-			ch <- prometheus.MustNewConstMetric(
-				c.cpuPerCore,
-				prometheus.GaugeValue,
-				usage,
-				fmt.Sprintf("%d", i),
-			)
+	// Collect CPU usage since the previous sample
+	if times, err := cpu.Times(false); err == nil && len(times) > 0 {
+		t := times[0]
+		if c.prevCPU != nil {
+			s.system.CPU = &CPUMetrics{UsagePercent: cpuBusyPercent(*c.prevCPU, t)}
+			add(c.cpuUsage, s.system.CPU.UsagePercent)
+		}
+		if advance {
+			c.prevCPU = &t
+		}
+
+		// Guest time is already included in user and nice time
+		addCounter(c.cpuTime, t.User, "user")
+		addCounter(c.cpuTime, t.Nice, "nice")
+		addCounter(c.cpuTime, t.System, "system")
+		addCounter(c.cpuTime, t.Idle, "idle")
+		addCounter(c.cpuTime, t.Iowait, "iowait")
+		addCounter(c.cpuTime, t.Irq, "irq")
+		addCounter(c.cpuTime, t.Softirq, "softirq")
+		addCounter(c.cpuTime, t.Steal, "steal")
+	}
+
+	if perCPU, err := cpu.Times(true); err == nil {
+		// CPUs that came online since the previous sample are skipped
+		for i := 0; i < len(perCPU) && i < len(c.prevPerCPU); i++ {
+			usage := cpuBusyPercent(c.prevPerCPU[i], perCPU[i])
+			add(c.cpuPerCore, usage, fmt.Sprintf("%d", i))
+			if s.system.CPU != nil {
+				s.system.CPU.PerCPU = append(s.system.CPU.PerCPU, usage)
+			}
+		}
+		if advance {
+			c.prevPerCPU = perCPU
 		}
 	}
 
 	// Collect memory metrics
-	if v, err := mem.VirtualMemory(); err == nil {
-		ch <- prometheus.MustNewConstMetric(
-			c.memoryTotal,
-			prometheus.GaugeValue,
-			float64(v.Total),
-		)
-		ch <- prometheus.MustNewConstMetric(
-			c.memoryUsed,
-			prometheus.GaugeValue,
-			float64(v.Used),
-		)
-		ch <- prometheus.MustNewConstMetric(
-			c.memoryFree,
-			prometheus.GaugeValue,
-			float64(v.Free),
-		)
-		ch <- prometheus.MustNewConstMetric(
-			c.memoryPercent,
-			prometheus.GaugeValue,
-			v.UsedPercent,
-		)
+	if v, err := mem.VirtualMemory(); err != nil {
+		s.fail(SectionMemory, fmt.Errorf("failed to get memory usage: %w", err))
+	} else {
+		s.system.Memory = &MemoryMetrics{
+			Total:       v.Total,
+			Used:        v.Used,
+			Free:        v.Free,
+			UsedPercent: v.UsedPercent,
+		}
+		add(c.memoryTotal, float64(v.Total))
+		add(c.memoryUsed, float64(v.Used))
+		add(c.memoryFree, float64(v.Free))
+		add(c.memoryPercent, v.UsedPercent)
+		add(c.memoryAvailable, float64(v.Available))
+		add(c.memoryBuffers, float64(v.Buffers))
+		add(c.memoryCached, float64(v.Cached))
+		add(c.memoryDirty, float64(v.Dirty))
+		add(c.memorySlab, float64(v.Slab))
+	}
+
+	// Collect swap metrics
+	if swap, err := mem.SwapMemory(); err == nil {
+		add(c.swapTotal, float64(swap.Total))
+		add(c.swapUsed, float64(swap.Used))
+		addCounter(c.swapIn, float64(swap.Sin))
+		addCounter(c.swapOut, float64(swap.Sout))
 	}
 
-	// Collect disk metrics
-	if partitions, err := disk.Partitions(false); err == nil {
+	// Collect load averages
+	if avg, err := load.Avg(); err == nil {
+		add(c.load1, avg.Load1)
+		add(c.load5, avg.Load5)
+		add(c.load15, avg.Load15)
+	}
+
+	// Collect disk metrics for every matching filesystem
+	s.system.Disk = &DiskMetrics{}
+	if partitions, err := c.diskFilter.Load().Partitions(); err != nil {
+		s.fail(SectionDisk, fmt.Errorf("failed to get disk usage: %w", err))
+	} else {
+		s.system.Disk.Filesystems = make([]FilesystemMetrics, 0, len(partitions))
 		for _, partition := range partitions {
-			if usage, err := disk.Usage(partition.Mountpoint); err == nil {
-				ch <- prometheus.MustNewConstMetric(
-					c.diskTotal,
-					prometheus.GaugeValue,
-					float64(usage.Total),
-				)
-				ch <- prometheus.MustNewConstMetric(
-					c.diskUsed,
-					prometheus.GaugeValue,
-					float64(usage.Used),
-				)
-				ch <- prometheus.MustNewConstMetric(
-					c.diskFree,
-					prometheus.GaugeValue,
-					float64(usage.Free),
-				)
-				ch <- prometheus.MustNewConstMetric(
-					c.diskPercent,
-					prometheus.GaugeValue,
-					usage.UsedPercent,
-				)
-				break // Only use root partition
+			usage, err := disk.Usage(partition.Mountpoint)
+			if err != nil {
+				// The filesystem may have been unmounted since it was listed
+				continue
+			}
+			s.system.Disk.Filesystems = append(s.system.Disk.Filesystems, FilesystemMetrics{
+				Device:      partition.Device,
+				Mountpoint:  partition.Mountpoint,
+				Fstype:      partition.Fstype,
+				Total:       usage.Total,
+				Used:        usage.Used,
+				Free:        usage.Free,
+				UsedPercent: usage.UsedPercent,
+				InodesTotal: usage.InodesTotal,
+				InodesUsed:  usage.InodesUsed,
+				InodesFree:  usage.InodesFree,
+			})
+			labels := []string{partition.Device, partition.Mountpoint, partition.Fstype}
+			add(c.diskTotal, float64(usage.Total), labels...)
+			add(c.diskUsed, float64(usage.Used), labels...)
+			add(c.diskFree, float64(usage.Free), labels...)
+			add(c.diskPercent, usage.UsedPercent, labels...)
+			// Some filesystems, such as btrfs, do not have a fixed number of inodes
+			if usage.InodesTotal > 0 {
+				add(c.diskInodesTotal, float64(usage.InodesTotal), labels...)
+				add(c.diskInodesUsed, float64(usage.InodesUsed), labels...)
+				add(c.diskInodesFree, float64(usage.InodesFree), labels...)
 			}
 		}
 	}
 
 	// Collect disk I/O metrics
-	if iostats, err := disk.IOCounters(); err == nil {
+	if iostats, err := disk.IOCounters(); err != nil {
+		s.fail(SectionDisk, fmt.Errorf("failed to get disk I/O counters: %w", err))
+	} else {
+		s.system.Disk.IOCounters = iostats
 		for device, stats := range iostats {
-			ch <- prometheus.MustNewConstMetric(
-				c.diskIO,
-				prometheus.GaugeValue,
-				float64(stats.ReadBytes),
-				device, "read",
-			)
-			ch <- prometheus.MustNewConstMetric(
-				c.diskIO,
-				prometheus.GaugeValue,
-				float64(stats.WriteBytes),
-				device, "write",
-			)
+			addCounter(c.diskIO, float64(stats.ReadBytes), device, "read")
+			addCounter(c.diskIO, float64(stats.WriteBytes), device, "write")
+			addCounter(c.diskIOOps, float64(stats.ReadCount), device, "read")
+			addCounter(c.diskIOOps, float64(stats.WriteCount), device, "write")
+			addCounter(c.diskIOMerged, float64(stats.MergedReadCount), device, "read")
+			addCounter(c.diskIOMerged, float64(stats.MergedWriteCount), device, "write")
+			// The kernel reports I/O times in milliseconds
+			addCounter(c.diskIOTime, msToSeconds(stats.ReadTime), device, "read")
+			addCounter(c.diskIOTime, msToSeconds(stats.WriteTime), device, "write")
+			addCounter(c.diskIOBusy, msToSeconds(stats.IoTime), device)
+			addCounter(c.diskIOWeighted, msToSeconds(stats.WeightedIO), device)
+			add(c.diskIOInFlight, float64(stats.IopsInProgress), device)
 		}
 	}
 
 	// Collect network metrics
-	if netStats, err := net.IOCounters(true); err == nil {
+	s.system.Network = &NetMetrics{}
+	if interfaces, err := net.Interfaces(); err != nil {
+		s.fail(SectionNetwork, fmt.Errorf("failed to get network interfaces: %w", err))
+	} else {
+		s.system.Network.Interfaces = make([]string, 0, len(interfaces))
+		for _, iface := range interfaces {
+			s.system.Network.Interfaces = append(s.system.Network.Interfaces, iface.Name)
+		}
+	}
+	if netStats, err := net.IOCounters(true); err != nil {
+		s.fail(SectionNetwork, fmt.Errorf("failed to get network I/O counters: %w", err))
+	} else {
+		s.system.Network.IOCounters = make(map[string]net.IOCountersStat, len(netStats))
 		for _, stats := range netStats {
-			ch <- prometheus.MustNewConstMetric(
-				c.networkIO,
-				prometheus.GaugeValue,
-				float64(stats.BytesRecv),
-				stats.Name, "received",
-			)
-			ch <- prometheus.MustNewConstMetric(
-				c.networkIO,
-				prometheus.GaugeValue,
-				float64(stats.BytesSent),
-				stats.Name, "sent",
-			)
+			s.system.Network.IOCounters[stats.Name] = stats
+			addCounter(c.networkIO, float64(stats.BytesRecv), stats.Name, "received")
+			addCounter(c.networkIO, float64(stats.BytesSent), stats.Name, "sent")
+			addCounter(c.networkPackets, float64(stats.PacketsRecv), stats.Name, "received")
+			addCounter(c.networkPackets, float64(stats.PacketsSent), stats.Name, "sent")
+			addCounter(c.networkErrors, float64(stats.Errin), stats.Name, "received")
+			addCounter(c.networkErrors, float64(stats.Errout), stats.Name, "sent")
+			addCounter(c.networkDrops, float64(stats.Dropin), stats.Name, "received")
+			addCounter(c.networkDrops, float64(stats.Dropout), stats.Name, "sent")
 		}
 	}
 
 	// Collect host metrics
-	if hostInfo, err := host.Info(); err == nil {
-		ch <- prometheus.MustNewConstMetric(
-			c.hostUptime,
-			prometheus.GaugeValue,
-			float64(hostInfo.Uptime),
-		)
+	if info, err := GetHostInfo(); err != nil {
+		s.fail(SectionHost, err)
+	} else {
+		s.system.HostInfo = &info
+		add(c.hostUptime, float64(info.Uptime))
+	}
+
+	return s
+}
+
+// msToSeconds converts a duration in milliseconds to seconds
+func msToSeconds(ms uint64) float64 {
+	return float64(ms) / 1000
+}
+
+// cpuBusyPercent returns the percentage of time the CPU was busy between
+// two samples of its times
+func cpuBusyPercent(prev, cur cpu.TimesStat) float64 {
+	prevTotal, prevBusy := cpuBusy(prev)
+	curTotal, curBusy := cpuBusy(cur)
+	if curBusy <= prevBusy {
+		return 0
 	}
+	if curTotal <= prevTotal {
+		return 100
+	}
+	return math.Min(100, (curBusy-prevBusy)/(curTotal-prevTotal)*100)
+}
+
+// cpuBusy returns the total and busy time of t. Guest time is already
+// included in user and nice time on Linux, so it is not counted twice.
+func cpuBusy(t cpu.TimesStat) (total, busy float64) {
+	total = t.Total() - t.Guest - t.GuestNice
+	return total, total - t.Idle - t.Iowait
+}
+
+// GetHostInfo returns the hostname, OS, platform, uptime and boot time of
+// the host
+func GetHostInfo() (HostInfo, error) {
+	info, err := host.Info()
+	if err != nil {
+		return HostInfo{}, fmt.Errorf("failed to get host info: %w", err)
+	}
+
+	return HostInfo{
+		Hostname: info.Hostname,
+		OS:       info.OS,
+		Platform: info.Platform,
+		Uptime:   info.Uptime,
+		BootTime: info.BootTime,
+	}, nil
 }
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUBusyPercent(t *testing.T) {
+	prev := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 50}
+	tests := []struct {
+		name string
+		cur  cpu.TimesStat
+		want float64
+	}{
+		{"half busy", cpu.TimesStat{User: 130, System: 70, Idle: 840, Iowait: 60}, 50},
+		{"idle", cpu.TimesStat{User: 100, System: 50, Idle: 900, Iowait: 50}, 0},
+		{"guest time is part of user time", cpu.TimesStat{User: 200, System: 50, Idle: 900, Iowait: 50, Guest: 100}, 50},
+		{"counters reset", cpu.TimesStat{User: 10, Idle: 10}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.want, cpuBusyPercent(prev, tt.cur), 1e-9)
+		})
+	}
+}
+
+// lastSample returns the value of system_metrics_last_sample_timestamp_seconds
+func lastSample(t *testing.T, registry *prometheus.Registry) float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "system_metrics_last_sample_timestamp_seconds" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatal("system_metrics_last_sample_timestamp_seconds not gathered")
+	return 0
+}
+
+func TestCollectorServesScrapesFromSample(t *testing.T) {
+	collector := NewCollector(time.Hour)
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+
+	// Without the sampler every scrape samples the system
+	first := lastSample(t, registry)
+	time.Sleep(10 * time.Millisecond)
+	require.Greater(t, lastSample(t, registry), first)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- collector.Start(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return collector.snapshot.Load() != nil && collector.running.Load()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Scrapes are served from the latest sample
+	sampled := lastSample(t, registry)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, sampled, lastSample(t, registry))
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestCollectorReportsCPUUsageAfterFullInterval(t *testing.T) {
+	collector := NewCollector(time.Hour)
+
+	// Scrapes without the sampler neither report CPU usage nor seed it
+	require.Nil(t, collector.latest().system.CPU)
+	require.Nil(t, collector.prevCPU)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = collector.Start(ctx) }()
+	require.Eventually(t, collector.running.Load, 5*time.Second, 10*time.Millisecond)
+
+	// The first sample only seeds the CPU times
+	require.Nil(t, collector.latest().system.CPU)
+	collector.sampleMu.Lock()
+	require.NotNil(t, collector.prevCPU)
+	seeded := *collector.prevCPU
+	collector.sampleMu.Unlock()
+
+	cancel()
+	require.Eventually(t, func() bool { return !collector.running.Load() }, 5*time.Second, 10*time.Millisecond)
+
+	// Once a sample has been taken, scrapes report the usage since then
+	// without advancing it
+	require.NotNil(t, collector.latest().system.CPU)
+	collector.sampleMu.Lock()
+	defer collector.sampleMu.Unlock()
+	require.Equal(t, seeded, *collector.prevCPU)
+}
+
+func TestCollectorExportsIOCountersAsCounters(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewCollector(time.Hour))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	types := make(map[string]dto.MetricType)
+	for _, family := range families {
+		types[family.GetName()] = family.GetType()
+	}
+
+	for _, name := range []string{
+		"system_network_io_bytes_total",
+		"system_network_io_packets_total",
+		"system_network_io_errors_total",
+		"system_network_io_drops_total",
+	} {
+		require.Equal(t, dto.MetricType_COUNTER, types[name], name)
+	}
+
+	// Block devices are not always visible, e.g. in containers
+	if _, ok := types["system_disk_io_bytes_total"]; ok {
+		for _, name := range []string{
+			"system_disk_io_bytes_total",
+			"system_disk_io_operations_total",
+			"system_disk_io_merged_operations_total",
+			"system_disk_io_time_seconds_total",
+			"system_disk_io_busy_seconds_total",
+			"system_disk_io_weighted_seconds_total",
+		} {
+			require.Equal(t, dto.MetricType_COUNTER, types[name], name)
+		}
+		require.Equal(t, dto.MetricType_GAUGE, types["system_disk_io_in_progress"])
+	}
+
+	// The _total suffix is reserved for counters
+	for name, typ := range types {
+		if strings.HasSuffix(name, "_total") {
+			require.Equal(t, dto.MetricType_COUNTER, typ, name)
+		}
+	}
+	if _, ok := types["system_disk_inodes_used"]; ok {
+		require.Equal(t, dto.MetricType_GAUGE, types["system_disk_inodes"])
+	}
+}
+
+func TestCollectorReportsLoadSwapAndMemoryBreakdown(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewCollector(time.Hour))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+
+	for name, typ := range map[string]dto.MetricType{
+		"system_load1":                  dto.MetricType_GAUGE,
+		"system_load5":                  dto.MetricType_GAUGE,
+		"system_load15":                 dto.MetricType_GAUGE,
+		"system_memory_available_bytes": dto.MetricType_GAUGE,
+		"system_memory_buffers_bytes":   dto.MetricType_GAUGE,
+		"system_memory_cached_bytes":    dto.MetricType_GAUGE,
+		"system_memory_dirty_bytes":     dto.MetricType_GAUGE,
+		"system_memory_slab_bytes":      dto.MetricType_GAUGE,
+		"system_swap_total_bytes":       dto.MetricType_GAUGE,
+		"system_swap_used_bytes":        dto.MetricType_GAUGE,
+		"system_swap_in_bytes_total":    dto.MetricType_COUNTER,
+		"system_swap_out_bytes_total":   dto.MetricType_COUNTER,
+		"system_cpu_time_seconds_total": dto.MetricType_COUNTER,
+	} {
+		require.Contains(t, byName, name)
+		require.Equal(t, typ, byName[name].GetType(), name)
+	}
+	require.NotZero(t, byName["system_memory_available_bytes"].GetMetric()[0].GetGauge().GetValue())
+
+	var modes []string
+	for _, m := range byName["system_cpu_time_seconds_total"].GetMetric() {
+		modes = append(modes, m.GetLabel()[0].GetValue())
+	}
+	require.ElementsMatch(t, []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}, modes)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+// pseudoFilesystems are the filesystem types that do not store data on a
+// disk. They are skipped unless included by a filesystem type pattern.
+var pseudoFilesystems = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"overlay":     true,
+	"proc":        true,
+	"procfs":      true,
+	"pstore":      true,
+	"ramfs":       true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"squashfs":    true,
+	"sysfs":       true,
+	"tracefs":     true,
+}
+
+// DiskFilter selects the filesystems reported by the Collector. Each
+// pattern must match the whole mountpoint or filesystem type.
+type DiskFilter struct {
+	includeMountpoints []*regexp.Regexp
+	excludeMountpoints []*regexp.Regexp
+	includeFSTypes     []*regexp.Regexp
+	excludeFSTypes     []*regexp.Regexp
+}
+
+// NewDiskFilter creates a filter from the disk settings of cfg
+func NewDiskFilter(cfg config.MetricsConfig) (*DiskFilter, error) {
+	f := &DiskFilter{}
+	var err error
+	if f.includeMountpoints, err = compilePatterns(cfg.DiskIncludeMountpoints); err != nil {
+		return nil, fmt.Errorf("invalid metrics.disk_include_mountpoints: %w", err)
+	}
+	if f.excludeMountpoints, err = compilePatterns(cfg.DiskExcludeMountpoints); err != nil {
+		return nil, fmt.Errorf("invalid metrics.disk_exclude_mountpoints: %w", err)
+	}
+	if f.includeFSTypes, err = compilePatterns(cfg.DiskIncludeFSTypes); err != nil {
+		return nil, fmt.Errorf("invalid metrics.disk_include_fstypes: %w", err)
+	}
+	if f.excludeFSTypes, err = compilePatterns(cfg.DiskExcludeFSTypes); err != nil {
+		return nil, fmt.Errorf("invalid metrics.disk_exclude_fstypes: %w", err)
+	}
+	return f, nil
+}
+
+// Match reports whether the partition should be reported. Pseudo
+// filesystems are skipped unless their type is explicitly included.
+func (f *DiskFilter) Match(p disk.PartitionStat) bool {
+	if len(f.includeFSTypes) > 0 {
+		if !matchAny(f.includeFSTypes, p.Fstype) {
+			return false
+		}
+	} else if pseudoFilesystems[p.Fstype] {
+		return false
+	}
+	if matchAny(f.excludeFSTypes, p.Fstype) {
+		return false
+	}
+	if len(f.includeMountpoints) > 0 && !matchAny(f.includeMountpoints, p.Mountpoint) {
+		return false
+	}
+	return !matchAny(f.excludeMountpoints, p.Mountpoint)
+}
+
+// Partitions returns the mounted partitions that match the filter. A
+// mountpoint that is mounted several times is reported once, for the most
+// recent mount, which is the one visible at that path.
+func (f *DiskFilter) Partitions() ([]disk.PartitionStat, error) {
+	partitions, err := disk.Partitions(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	index := make(map[string]int)
+	var result []disk.PartitionStat
+	for _, p := range partitions {
+		if !f.Match(p) {
+			continue
+		}
+		if i, ok := index[p.Mountpoint]; ok {
+			result[i] = p
+			continue
+		}
+		index[p.Mountpoint] = len(result)
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// compilePatterns compiles patterns anchored to match whole values
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchAny reports whether any of patterns matches value
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, re := range patterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
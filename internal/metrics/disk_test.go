@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+func TestDiskFilterMatch(t *testing.T) {
+	root := disk.PartitionStat{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"}
+	data := disk.PartitionStat{Device: "/dev/sdb1", Mountpoint: "/mnt/celestia-data", Fstype: "xfs"}
+	boot := disk.PartitionStat{Device: "/dev/sda2", Mountpoint: "/boot/efi", Fstype: "vfat"}
+	shm := disk.PartitionStat{Device: "tmpfs", Mountpoint: "/dev/shm", Fstype: "tmpfs"}
+	proc := disk.PartitionStat{Device: "proc", Mountpoint: "/proc", Fstype: "proc"}
+
+	tests := []struct {
+		name    string
+		cfg     config.MetricsConfig
+		matches []disk.PartitionStat
+	}{
+		{
+			name:    "pseudo filesystems are skipped by default",
+			matches: []disk.PartitionStat{root, data, boot, shm},
+		},
+		{
+			name:    "include mountpoints",
+			cfg:     config.MetricsConfig{DiskIncludeMountpoints: []string{"/", "/mnt/celestia.*"}},
+			matches: []disk.PartitionStat{root, data},
+		},
+		{
+			name:    "patterns match whole values",
+			cfg:     config.MetricsConfig{DiskExcludeMountpoints: []string{"/boot", "/dev"}},
+			matches: []disk.PartitionStat{root, data, boot, shm},
+		},
+		{
+			name:    "exclude mountpoints and filesystem types",
+			cfg:     config.MetricsConfig{DiskExcludeMountpoints: []string{"/boot/.*"}, DiskExcludeFSTypes: []string{"tmpfs"}},
+			matches: []disk.PartitionStat{root, data},
+		},
+		{
+			name:    "included filesystem types override the pseudo filesystem list",
+			cfg:     config.MetricsConfig{DiskIncludeFSTypes: []string{"ext4", "proc"}},
+			matches: []disk.PartitionStat{root, proc},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewDiskFilter(tt.cfg)
+			require.NoError(t, err)
+
+			var matches []disk.PartitionStat
+			for _, p := range []disk.PartitionStat{root, data, boot, shm, proc} {
+				if filter.Match(p) {
+					matches = append(matches, p)
+				}
+			}
+			require.Equal(t, tt.matches, matches)
+		})
+	}
+
+	_, err := NewDiskFilter(config.MetricsConfig{DiskIncludeFSTypes: []string{"ext[4"}})
+	require.Error(t, err)
+}
+
+func TestCollectorReportsDiskPerMountpoint(t *testing.T) {
+	filter, err := NewDiskFilter(config.MetricsConfig{})
+	require.NoError(t, err)
+	partitions, err := filter.Partitions()
+	require.NoError(t, err)
+	if len(partitions) == 0 {
+		t.Skip("no disk filesystems mounted")
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	collector := NewCollector(0)
+	registry.MustRegister(collector)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	mountpoints := make(map[string]bool)
+	for _, family := range families {
+		if family.GetName() != "system_disk_total_bytes" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "mountpoint" {
+					mountpoints[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, mountpoints)
+	for mountpoint := range mountpoints {
+		require.NotContains(t, []string{"/proc", "/sys"}, mountpoint)
+	}
+
+	// Excluding every mountpoint removes the disk usage metrics
+	filter, err = NewDiskFilter(config.MetricsConfig{DiskExcludeMountpoints: []string{".*"}})
+	require.NoError(t, err)
+	collector.SetDiskFilter(filter)
+	count, err := testutil.GatherAndCount(registry, "system_disk_total_bytes")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// Gather returns the metric families gathered from gatherer. A collector
+// that fails does not prevent the others from being gathered, so the error
+// is only logged.
+func Gather(gatherer prometheus.Gatherer) []*dto.MetricFamily {
+	families, err := gatherer.Gather()
+	if err != nil {
+		logging.Warn().Err(err).Msg("Some metrics could not be gathered")
+	}
+	return families
+}
+
+// exportEvery calls export every interval until ctx is done. Errors are
+// logged and do not stop the loop. exporter names the exporter in the logs.
+func exportEvery(ctx context.Context, exporter string, interval time.Duration, export func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logging.Info().
+		Str("exporter", exporter).
+		Str("interval", interval.String()).
+		Msg("Starting metrics export")
+
+	for {
+		select {
+		case <-ctx.Done():
+			logging.Info().Str("exporter", exporter).Msg("Stopping metrics export")
+			return ctx.Err()
+		case <-ticker.C:
+			if err := export(ctx); err != nil {
+				logging.Error().Err(err).Str("exporter", exporter).Msg("Failed to export metrics")
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/celestiaorg/talis-agent/internal/api"
+	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// otlpScope is the instrumentation scope name of the exported metrics
+const otlpScope = "github.com/celestiaorg/talis-agent"
+
+// aggregationTemporalityCumulative is the OTLP AGGREGATION_TEMPORALITY_CUMULATIVE value
+const aggregationTemporalityCumulative = 2
+
+// systemMetricPrefix names the metrics of the Collector, whose counters
+// read the kernel's totals since boot
+const systemMetricPrefix = "system_"
+
+// OTLP/HTTP JSON request body, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpInstrumentationScope `json:"scope"`
+		Metrics []otlpMetric             `json:"metrics"`
+	}
+	otlpInstrumentationScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description,omitempty"`
+		Gauge       *otlpGauge `json:"gauge,omitempty"`
+		Sum         *otlpSum   `json:"sum,omitempty"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpDataPoint struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+		// Timestamps are 64-bit integers, which OTLP/JSON encodes as strings
+		StartTimeUnixNano string  `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string  `json:"timeUnixNano"`
+		AsDouble          float64 `json:"asDouble"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// OTLPExporter periodically exports the agent's gauges and counters to an
+// OpenTelemetry collector over OTLP/HTTP with JSON encoding
+type OTLPExporter struct {
+	gatherer prometheus.Gatherer
+	client   *api.Client
+	header   http.Header
+	interval time.Duration
+	resource otlpResource
+	// startTime is when the agent's own counters started counting and
+	// bootTime when the system counters, which count since boot, did
+	startTime time.Time
+	bootTime  time.Time
+}
+
+// NewOTLPExporter creates an exporter for cfg.OTLP that exports the metrics
+// gathered from gatherer. Resource attributes are derived from host and
+// extended with the configured ones.
+func NewOTLPExporter(cfg *config.Config, gatherer prometheus.Gatherer, host HostInfo) (*OTLPExporter, error) {
+	clientConfig, err := retryClientConfig(cfg.API)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(cfg.OTLP.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp.interval: %w", err)
+	}
+	clientConfig.RequestTimeout, err = time.ParseDuration(cfg.OTLP.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp.timeout: %w", err)
+	}
+	clientConfig.BaseURL = cfg.OTLP.Endpoint
+
+	header := http.Header{}
+	for name, value := range cfg.OTLP.Headers {
+		header.Set(name, value)
+	}
+	header.Set("Content-Type", "application/json")
+
+	attributes := map[string]string{
+		"service.name": "talis-agent",
+		"host.name":    host.Hostname,
+		"os.type":      host.OS,
+		"os.name":      host.Platform,
+	}
+	for key, value := range cfg.OTLP.ResourceAttributes {
+		attributes[key] = value
+	}
+
+	startTime := time.Now()
+	bootTime := startTime
+	if host.BootTime > 0 {
+		bootTime = time.Unix(int64(host.BootTime), 0)
+	}
+
+	return &OTLPExporter{
+		gatherer:  gatherer,
+		client:    api.NewClient(clientConfig),
+		header:    header,
+		interval:  interval,
+		resource:  otlpResource{Attributes: otlpAttributes(attributes)},
+		startTime: startTime,
+		bootTime:  bootTime,
+	}, nil
+}
+
+// Start exports metrics every interval until ctx is done
+func (e *OTLPExporter) Start(ctx context.Context) error {
+	return exportEvery(ctx, "otlp", e.interval, e.export)
+}
+
+// export gathers the current metrics and sends them in one request
+func (e *OTLPExporter) export(ctx context.Context) error {
+	families := Gather(e.gatherer)
+
+	body, err := json.Marshal(e.request(families, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+	if _, err := e.client.RawRequest(ctx, http.MethodPost, "", body, e.header); err != nil {
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+
+	logging.Debug().Int("families", len(families)).Msg("Metrics exported over OTLP")
+	return nil
+}
+
+// request converts gathered metric families into an OTLP request. Gauges
+// and untyped metrics become gauges, counters become cumulative monotonic
+// sums starting at boot for the system_* counters and at agent start for
+// the others; other types and NaN or infinite values are skipped.
+func (e *OTLPExporter) request(families []*dto.MetricFamily, now time.Time) *otlpRequest {
+	var metrics []otlpMetric
+	for _, family := range families {
+		start := e.startTime
+		if strings.HasPrefix(family.GetName(), systemMetricPrefix) {
+			start = e.bootTime
+		}
+
+		var points []otlpDataPoint
+		for _, m := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			ts := now
+			if m.TimestampMs != nil {
+				ts = time.UnixMilli(m.GetTimestampMs())
+			}
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			point := otlpDataPoint{
+				Attributes:   otlpAttributes(labels),
+				TimeUnixNano: unixNano(ts),
+				AsDouble:     value,
+			}
+			if family.GetType() == dto.MetricType_COUNTER {
+				point.StartTimeUnixNano = unixNano(start)
+			}
+			points = append(points, point)
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		if family.GetType() == dto.MetricType_COUNTER {
+			metric.Sum = &otlpSum{
+				DataPoints:             points,
+				AggregationTemporality: aggregationTemporalityCumulative,
+				IsMonotonic:            true,
+			}
+		} else {
+			metric.Gauge = &otlpGauge{DataPoints: points}
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return &otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: e.resource,
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpInstrumentationScope{Name: otlpScope},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes converts a map into OTLP string attributes sorted by key
+func otlpAttributes(m map[string]string) []otlpKeyValue {
+	attributes := make([]otlpKeyValue, 0, len(m))
+	for key, value := range m {
+		attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}})
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+	return attributes
+}
+
+// unixNano formats t as an OTLP/JSON timestamp
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+func TestOTLPExporterExport(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	headers := make(chan http.Header, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		headers <- r.Header
+		requests <- req
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_usage_percent", Help: "Usage"}, []string{"core"})
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_events", Help: "Events"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_latency_seconds", Help: "Latency"})
+	nan := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_nan", Help: "Not a number"})
+	system := prometheus.NewCounter(prometheus.CounterOpts{Name: "system_test_bytes_total", Help: "Bytes since boot"})
+	registry.MustRegister(gauge, counter, histogram, nan, system)
+	gauge.WithLabelValues("0").Set(12.5)
+	counter.Add(4)
+	system.Add(1024)
+	histogram.Observe(1)
+	nan.Set(math.NaN())
+
+	cfg := config.DefaultConfig()
+	cfg.OTLP.Endpoint = receiver.URL + "/v1/metrics"
+	cfg.OTLP.Headers = map[string]string{"X-Api-Key": "otlp-key"}
+	cfg.OTLP.ResourceAttributes = map[string]string{"network": "mocha-4"}
+	host := HostInfo{Hostname: "validator-1", OS: "linux", Platform: "ubuntu", BootTime: 1700000000}
+	exporter, err := NewOTLPExporter(cfg, registry, host)
+	require.NoError(t, err)
+
+	before := time.Now()
+	require.NoError(t, exporter.export(context.Background()))
+
+	header := <-headers
+	require.Equal(t, "application/json", header.Get("Content-Type"))
+	require.Equal(t, "otlp-key", header.Get("X-Api-Key"))
+
+	req := <-requests
+	require.Len(t, req.ResourceMetrics, 1)
+	rm := req.ResourceMetrics[0]
+	require.Equal(t, []otlpKeyValue{
+		{Key: "host.name", Value: otlpAnyValue{StringValue: "validator-1"}},
+		{Key: "network", Value: otlpAnyValue{StringValue: "mocha-4"}},
+		{Key: "os.name", Value: otlpAnyValue{StringValue: "ubuntu"}},
+		{Key: "os.type", Value: otlpAnyValue{StringValue: "linux"}},
+		{Key: "service.name", Value: otlpAnyValue{StringValue: "talis-agent"}},
+	}, rm.Resource.Attributes)
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := make(map[string]otlpMetric)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Len(t, metrics, 3, "histograms and NaN gauges are skipped")
+
+	usage := metrics["test_usage_percent"]
+	require.Equal(t, "Usage", usage.Description)
+	require.NotNil(t, usage.Gauge)
+	require.Len(t, usage.Gauge.DataPoints, 1)
+	point := usage.Gauge.DataPoints[0]
+	require.Equal(t, 12.5, point.AsDouble)
+	require.Equal(t, []otlpKeyValue{{Key: "core", Value: otlpAnyValue{StringValue: "0"}}}, point.Attributes)
+	ts, err := strconv.ParseInt(point.TimeUnixNano, 10, 64)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, ts, before.UnixNano())
+
+	events := metrics["test_events"]
+	require.NotNil(t, events.Sum)
+	require.True(t, events.Sum.IsMonotonic)
+	require.Equal(t, aggregationTemporalityCumulative, events.Sum.AggregationTemporality)
+	require.Equal(t, 4.0, events.Sum.DataPoints[0].AsDouble)
+	start, err := strconv.ParseInt(events.Sum.DataPoints[0].StartTimeUnixNano, 10, 64)
+	require.NoError(t, err)
+	require.LessOrEqual(t, start, before.UnixNano())
+	require.Greater(t, start, int64(1700000000)*int64(time.Second))
+
+	// System counters count since boot
+	bytes := metrics["system_test_bytes_total"]
+	require.NotNil(t, bytes.Sum)
+	require.Equal(t, "1700000000000000000", bytes.Sum.DataPoints[0].StartTimeUnixNano)
+}
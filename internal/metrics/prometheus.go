@@ -1,54 +1,47 @@
 package metrics
 
 import (
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
 	// promMetrics is the singleton instance of PrometheusMetrics
-	promMetrics *PrometheusMetrics
+	promMetrics     *PrometheusMetrics
+	promMetricsOnce sync.Once
 )
 
 // PrometheusMetrics holds all Prometheus metrics for the agent
 type PrometheusMetrics struct {
-	// System metrics
-	cpuUsage    prometheus.Gauge
-	memoryUsage prometheus.Gauge
-	diskUsage   prometheus.Gauge
-
 	// Agent metrics
 	uptime           prometheus.Counter
 	checkinTimestamp prometheus.Gauge
 	payloadReceived  prometheus.Counter
 	commandSuccess   prometheus.Counter
 	commandFailure   prometheus.Counter
+
+	// Remote write metrics
+	remoteWriteSent    prometheus.Counter
+	remoteWriteDropped prometheus.Counter
+
+	// Telemetry queue metrics
+	queueDepth     prometheus.Gauge
+	queueOldestAge prometheus.Gauge
 }
 
 // GetPrometheusMetrics returns the singleton instance of PrometheusMetrics
 func GetPrometheusMetrics() *PrometheusMetrics {
-	if promMetrics == nil {
+	promMetricsOnce.Do(func() {
 		promMetrics = newPrometheusMetrics()
-	}
+	})
 	return promMetrics
 }
 
 // newPrometheusMetrics creates a new PrometheusMetrics instance
 func newPrometheusMetrics() *PrometheusMetrics {
 	pm := &PrometheusMetrics{
-		// System metrics
-		cpuUsage: prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: "system_cpu_usage_percent",
-			Help: "Current CPU usage percentage",
-		}),
-		memoryUsage: prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: "system_memory_usage_percent",
-			Help: "Current memory usage percentage",
-		}),
-		diskUsage: prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: "system_disk_usage_percent",
-			Help: "Current disk usage percentage",
-		}),
-
 		// Agent metrics
 		uptime: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "agent_uptime_seconds",
@@ -70,28 +63,42 @@ func newPrometheusMetrics() *PrometheusMetrics {
 			Name: "agent_command_executions_failure",
 			Help: "Number of failed command executions",
 		}),
+
+		// Remote write metrics
+		remoteWriteSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "agent_remote_write_samples_sent",
+			Help: "Number of samples pushed to the remote write endpoint",
+		}),
+		remoteWriteDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "agent_remote_write_samples_dropped",
+			Help: "Number of samples dropped because they could not be pushed to the remote write endpoint",
+		}),
+
+		// Telemetry queue metrics
+		queueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_telemetry_queue_depth",
+			Help: "Number of check-ins and metric batches waiting to be sent to the API",
+		}),
+		queueOldestAge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "agent_telemetry_queue_oldest_age_seconds",
+			Help: "Age of the oldest entry waiting to be sent to the API",
+		}),
 	}
 
-	// Register all metrics
-	prometheus.MustRegister(pm.cpuUsage)
-	prometheus.MustRegister(pm.memoryUsage)
-	prometheus.MustRegister(pm.diskUsage)
+	// Register agent metrics
 	prometheus.MustRegister(pm.uptime)
 	prometheus.MustRegister(pm.checkinTimestamp)
 	prometheus.MustRegister(pm.payloadReceived)
 	prometheus.MustRegister(pm.commandSuccess)
 	prometheus.MustRegister(pm.commandFailure)
+	prometheus.MustRegister(pm.remoteWriteSent)
+	prometheus.MustRegister(pm.remoteWriteDropped)
+	prometheus.MustRegister(pm.queueDepth)
+	prometheus.MustRegister(pm.queueOldestAge)
 
 	return pm
 }
 
-// UpdateSystemMetrics updates the system-related Prometheus metrics
-func (pm *PrometheusMetrics) UpdateSystemMetrics(metrics *SystemMetrics) {
-	pm.cpuUsage.Set(metrics.CPU.UsagePercent)
-	pm.memoryUsage.Set(metrics.Memory.UsedPercent)
-	pm.diskUsage.Set(metrics.Disk.UsedPercent)
-}
-
 // RecordUptime increments the uptime counter
 func (pm *PrometheusMetrics) RecordUptime(seconds float64) {
 	pm.uptime.Add(seconds)
@@ -115,3 +122,18 @@ func (pm *PrometheusMetrics) RecordCommandExecution(success bool) {
 		pm.commandFailure.Inc()
 	}
 }
+
+// RecordRemoteWrite records the outcome of a remote write push of n samples
+func (pm *PrometheusMetrics) RecordRemoteWrite(n int, success bool) {
+	if success {
+		pm.remoteWriteSent.Add(float64(n))
+	} else {
+		pm.remoteWriteDropped.Add(float64(n))
+	}
+}
+
+// RecordTelemetryQueue records the depth and oldest entry age of the telemetry queue
+func (pm *PrometheusMetrics) RecordTelemetryQueue(depth int, oldest time.Duration) {
+	pm.queueDepth.Set(float64(depth))
+	pm.queueOldestAge.Set(oldest.Seconds())
+}
@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/celestiaorg/talis-agent/internal/api"
+	"github.com/celestiaorg/talis-agent/internal/config"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// RemoteWriter periodically pushes the agent's metrics to a Prometheus
+// remote write endpoint, for agents that cannot be scraped
+type RemoteWriter struct {
+	gatherer prometheus.Gatherer
+	client   *api.Client
+	header   http.Header
+	interval time.Duration
+	host     string
+	labels   map[string]string
+	metrics  *PrometheusMetrics
+}
+
+// NewRemoteWriter creates a remote writer for cfg.RemoteWrite that pushes
+// the metrics gathered from gatherer
+func NewRemoteWriter(cfg *config.Config, gatherer prometheus.Gatherer) (*RemoteWriter, error) {
+	rw := cfg.RemoteWrite
+
+	clientConfig, err := retryClientConfig(cfg.API)
+	if err != nil {
+		return nil, err
+	}
+	interval, err := time.ParseDuration(rw.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote_write.interval: %w", err)
+	}
+	clientConfig.RequestTimeout, err = time.ParseDuration(rw.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote_write.timeout: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set("Content-Encoding", "snappy")
+	header.Set("User-Agent", "talis-agent")
+	header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	clientConfig.BaseURL = rw.URL
+	clientConfig.Token, err = rw.ResolveBearerToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve remote write bearer token: %w", err)
+	}
+	if rw.Username != "" {
+		password, err := rw.ResolvePassword()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve remote write password: %w", err)
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(rw.Username + ":" + password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	return &RemoteWriter{
+		gatherer: gatherer,
+		client:   api.NewClient(clientConfig),
+		header:   header,
+		interval: interval,
+		host:     host,
+		labels:   rw.ExternalLabels,
+		metrics:  GetPrometheusMetrics(),
+	}, nil
+}
+
+// Start pushes metrics every interval until ctx is done
+func (w *RemoteWriter) Start(ctx context.Context) error {
+	return exportEvery(ctx, "remote_write", w.interval, w.push)
+}
+
+// push gathers the current metrics and sends them in one WriteRequest.
+// Samples that cannot be delivered are counted as dropped.
+func (w *RemoteWriter) push(ctx context.Context) error {
+	families := Gather(w.gatherer)
+
+	samples := NewBatch(w.host, families, time.Now()).Samples
+	body := s2.EncodeSnappy(nil, encodeWriteRequest(samples, w.externalLabels()))
+	if _, err := w.client.RawRequest(ctx, http.MethodPost, "", body, w.header); err != nil {
+		w.metrics.RecordRemoteWrite(len(samples), false)
+		return fmt.Errorf("failed to push %d samples: %w", len(samples), err)
+	}
+
+	w.metrics.RecordRemoteWrite(len(samples), true)
+	logging.Debug().Int("samples", len(samples)).Msg("Metrics pushed to remote write endpoint")
+	return nil
+}
+
+// externalLabels returns the configured external labels plus an instance
+// label with the hostname unless one is configured
+func (w *RemoteWriter) externalLabels() map[string]string {
+	labels := map[string]string{"instance": w.host}
+	for name, value := range w.labels {
+		labels[name] = value
+	}
+	return labels
+}
+
+// encodeWriteRequest encodes samples as a Prometheus remote write
+// WriteRequest protobuf message, one time series per sample. External
+// labels are added to series that do not already have a label of the same
+// name.
+func encodeWriteRequest(samples []Sample, external map[string]string) []byte {
+	var buf []byte
+	for _, sample := range samples {
+		labels := make(map[string]string, len(sample.Labels)+len(external)+1)
+		for name, value := range external {
+			labels[name] = value
+		}
+		for name, value := range sample.Labels {
+			labels[name] = value
+		}
+		labels["__name__"] = sample.Name
+
+		// WriteRequest.timeseries = 1
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, encodeTimeSeries(labels, sample))
+	}
+	return buf
+}
+
+// encodeTimeSeries encodes a TimeSeries message with a single sample.
+// Labels are sorted by name as remote write requires.
+func encodeTimeSeries(labels map[string]string, sample Sample) []byte {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf []byte
+	for _, name := range names {
+		// Label.name = 1, Label.value = 2
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+
+		// TimeSeries.labels = 1
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, label)
+	}
+
+	// Sample.value = 1, Sample.timestamp = 2
+	var s []byte
+	s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+	s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+	s = protowire.AppendTag(s, 2, protowire.VarintType)
+	s = protowire.AppendVarint(s, uint64(sample.TimestampMs))
+
+	// TimeSeries.samples = 2
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	buf = protowire.AppendBytes(buf, s)
+	return buf
+}
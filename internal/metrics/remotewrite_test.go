@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+// series is a decoded remote write TimeSeries with a single sample
+type series struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes a WriteRequest produced by encodeWriteRequest
+func decodeWriteRequest(t *testing.T, data []byte) []series {
+	t.Helper()
+
+	var result []series
+	forEachField(t, data, func(num protowire.Number, value []byte) {
+		require.Equal(t, protowire.Number(1), num)
+		s := series{labels: make(map[string]string)}
+		forEachField(t, value, func(num protowire.Number, value []byte) {
+			switch num {
+			case 1:
+				var name, labelValue string
+				forEachField(t, value, func(num protowire.Number, value []byte) {
+					if num == 1 {
+						name = string(value)
+					} else {
+						labelValue = string(value)
+					}
+				})
+				s.labels[name] = labelValue
+			case 2:
+				for len(value) > 0 {
+					num, _, n := protowire.ConsumeTag(value)
+					require.GreaterOrEqual(t, n, 0)
+					value = value[n:]
+					if num == 1 {
+						bits, m := protowire.ConsumeFixed64(value)
+						require.GreaterOrEqual(t, m, 0)
+						s.value = math.Float64frombits(bits)
+						value = value[m:]
+					} else {
+						ts, m := protowire.ConsumeVarint(value)
+						require.GreaterOrEqual(t, m, 0)
+						s.timestamp = int64(ts)
+						value = value[m:]
+					}
+				}
+			}
+		})
+		result = append(result, s)
+	})
+	return result
+}
+
+// forEachField calls fn with every length-delimited field of a message
+func forEachField(t *testing.T, data []byte, fn func(protowire.Number, []byte)) {
+	t.Helper()
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.GreaterOrEqual(t, n, 0)
+		require.Equal(t, protowire.BytesType, typ)
+		value, m := protowire.ConsumeBytes(data[n:])
+		require.GreaterOrEqual(t, m, 0)
+		fn(num, value)
+		data = data[n+m:]
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	samples := []Sample{
+		{Name: "up", Value: 1, TimestampMs: 1000},
+		{Name: "temp", Labels: map[string]string{"sensor": "cpu", "network": "own"}, Value: 42.5, TimestampMs: 2000},
+	}
+
+	decoded := decodeWriteRequest(t, encodeWriteRequest(samples, map[string]string{"network": "mocha-4"}))
+	require.Equal(t, []series{
+		{labels: map[string]string{"__name__": "up", "network": "mocha-4"}, value: 1, timestamp: 1000},
+		{labels: map[string]string{"__name__": "temp", "network": "own", "sensor": "cpu"}, value: 42.5, timestamp: 2000},
+	}, decoded)
+}
+
+func TestRemoteWriterPush(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+		}
+		requests <- r
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"})
+	registry.MustRegister(gauge)
+	gauge.Set(7)
+
+	cfg := config.DefaultConfig()
+	cfg.RemoteWrite.URL = server.URL + "/api/v1/write"
+	cfg.RemoteWrite.Username = "agent"
+	cfg.RemoteWrite.Password = "secret"
+	cfg.RemoteWrite.ExternalLabels = map[string]string{"network": "mocha-4", "role": "validator"}
+	// The API token is not used by the remote writer
+	cfg.API.TokenFile = filepath.Join(t.TempDir(), "missing")
+	writer, err := NewRemoteWriter(cfg, registry)
+	require.NoError(t, err)
+
+	sent := testutil.ToFloat64(writer.metrics.remoteWriteSent)
+	require.NoError(t, writer.push(context.Background()))
+	require.Equal(t, sent+1, testutil.ToFloat64(writer.metrics.remoteWriteSent))
+
+	r := <-requests
+	require.Equal(t, "/api/v1/write", r.URL.Path)
+	require.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+	require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+	require.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+	username, password, ok := r.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "agent", username)
+	require.Equal(t, "secret", password)
+
+	data, err := s2.Decode(nil, <-bodies)
+	require.NoError(t, err)
+	decoded := decodeWriteRequest(t, data)
+	require.Len(t, decoded, 1)
+	require.Equal(t, 7.0, decoded[0].value)
+	require.Equal(t, "test_gauge", decoded[0].labels["__name__"])
+	require.Equal(t, "mocha-4", decoded[0].labels["network"])
+	require.Equal(t, "validator", decoded[0].labels["role"])
+	require.Equal(t, writer.host, decoded[0].labels["instance"])
+}
+
+func TestRemoteWriterCountsDroppedSamples(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"}))
+
+	cfg := config.DefaultConfig()
+	cfg.API.MaxRetries = 0
+	cfg.RemoteWrite.URL = server.URL
+	cfg.RemoteWrite.BearerToken = "push-token"
+	writer, err := NewRemoteWriter(cfg, registry)
+	require.NoError(t, err)
+
+	dropped := testutil.ToFloat64(writer.metrics.remoteWriteDropped)
+	require.Error(t, writer.push(context.Background()))
+	require.Equal(t, dropped+1, testutil.ToFloat64(writer.metrics.remoteWriteDropped))
+}
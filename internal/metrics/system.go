@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sections of a SystemMetrics snapshot, named after their JSON keys
+const (
+	SectionCPU     = "cpu"
+	SectionMemory  = "memory"
+	SectionDisk    = "disk"
+	SectionNetwork = "network"
+	SectionHost    = "host_info"
+)
+
+// Sections lists every section of a SystemMetrics snapshot
+var Sections = []string{SectionCPU, SectionMemory, SectionDisk, SectionNetwork, SectionHost}
+
+var (
+	// ErrUnknownSection is returned when a snapshot section does not exist
+	ErrUnknownSection = errors.New("unknown section")
+	// ErrNoCPUSample is returned when only the CPU section is requested
+	// before the Collector has sampled the CPU usage over a full interval
+	ErrNoCPUSample = errors.New("CPU usage has not been sampled yet")
+)
+
+// ParseSections parses a comma separated list of sections. An empty list
+// selects every section.
+func ParseSections(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return Sections, nil
+	}
+
+	var sections []string
+	for _, section := range strings.Split(list, ",") {
+		section = strings.TrimSpace(section)
+		if !isSection(section) {
+			return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnknownSection, section, strings.Join(Sections, ", "))
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// isSection reports whether name is a snapshot section
+func isSection(name string) bool {
+	for _, section := range Sections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns the given sections of the latest sample, or every
+// section if none are given, so all sections describe the same moment. CPU
+// usage covers the previous sampling interval and is omitted until one has
+// been sampled, unless it is the only section requested, which fails with
+// ErrNoCPUSample. Disk usage is reported for the filesystems selected by the
+// disk filter. It fails if any other section could not be read.
+func (c *Collector) Snapshot(sections ...string) (*SystemMetrics, error) {
+	if len(sections) == 0 {
+		sections = Sections
+	}
+
+	s := c.latest()
+	m := &SystemMetrics{Timestamp: s.system.Timestamp}
+	for _, section := range sections {
+		if err := s.errs[section]; err != nil {
+			return nil, err
+		}
+		switch section {
+		case SectionCPU:
+			if s.system.CPU == nil && len(sections) == 1 {
+				return nil, ErrNoCPUSample
+			}
+			m.CPU = s.system.CPU
+		case SectionMemory:
+			m.Memory = s.system.Memory
+		case SectionDisk:
+			m.Disk = s.system.Disk
+		case SectionNetwork:
+			m.Network = s.system.Network
+		case SectionHost:
+			m.HostInfo = s.system.HostInfo
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownSection, section)
+		}
+	}
+	return m, nil
+}
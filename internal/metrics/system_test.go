@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+func TestParseSections(t *testing.T) {
+	sections, err := ParseSections("")
+	require.NoError(t, err)
+	require.Equal(t, Sections, sections)
+
+	sections, err = ParseSections("cpu, memory")
+	require.NoError(t, err)
+	require.Equal(t, []string{SectionCPU, SectionMemory}, sections)
+
+	_, err = ParseSections("cpu,gpu")
+	require.ErrorIs(t, err, ErrUnknownSection)
+}
+
+// startCollector starts c and waits until its cached sample reports CPU
+// usage
+func startCollector(t *testing.T, c *Collector) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = c.Start(ctx) }()
+	require.Eventually(t, func() bool {
+		if !c.running.Load() {
+			return false
+		}
+		_, err := c.Snapshot(SectionCPU)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSnapshot(t *testing.T) {
+	c := NewCollector(10 * time.Millisecond)
+	filter, err := NewDiskFilter(config.MetricsConfig{
+		DiskIncludeMountpoints: []string{"/"},
+		DiskIncludeFSTypes:     []string{".*"},
+	})
+	require.NoError(t, err)
+	c.SetDiskFilter(filter)
+	startCollector(t, c)
+
+	m, err := c.Snapshot()
+	require.NoError(t, err)
+	require.NotNil(t, m.CPU)
+	require.NotEmpty(t, m.CPU.PerCPU)
+	require.NotZero(t, m.Memory.Total)
+	require.Len(t, m.Disk.Filesystems, 1)
+	require.Equal(t, "/", m.Disk.Filesystems[0].Mountpoint)
+	require.NotZero(t, m.Disk.Filesystems[0].Total)
+	require.NotNil(t, m.Network)
+	require.NotEmpty(t, m.HostInfo.Hostname)
+
+	m, err = c.Snapshot(SectionMemory)
+	require.NoError(t, err)
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+
+	// Sections that were not collected are left out
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &fields))
+	require.Len(t, fields, 2)
+	require.Contains(t, fields, "timestamp")
+	require.Contains(t, fields, "memory")
+}
+
+func TestSnapshotServesCPUFromSample(t *testing.T) {
+	c := NewCollector(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = c.Start(ctx) }()
+	require.Eventually(t, c.running.Load, 5*time.Second, 10*time.Millisecond)
+
+	// CPU usage is only reported once a full interval has been sampled
+	_, err := c.Snapshot(SectionCPU)
+	require.ErrorIs(t, err, ErrNoCPUSample)
+	m, err := c.Snapshot()
+	require.NoError(t, err)
+	require.Nil(t, m.CPU)
+	require.NotNil(t, m.Memory)
+
+	c.snapshot.Store(c.sample(true))
+	first, err := c.Snapshot(SectionCPU)
+	require.NoError(t, err)
+	second, err := c.Snapshot()
+	require.NoError(t, err)
+
+	// Every section comes from the same sample
+	require.Same(t, first.CPU, second.CPU)
+	require.Equal(t, first.Timestamp, second.Timestamp)
+	third, err := c.Snapshot(SectionMemory, SectionHost)
+	require.NoError(t, err)
+	require.Same(t, second.Memory, third.Memory)
+	require.Same(t, second.HostInfo, third.HostInfo)
+}
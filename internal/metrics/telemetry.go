@@ -2,8 +2,12 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,35 +16,99 @@ import (
 	"github.com/celestiaorg/talis-agent/internal/api"
 	"github.com/celestiaorg/talis-agent/internal/config"
 	"github.com/celestiaorg/talis-agent/internal/logging"
+	"github.com/celestiaorg/talis-agent/internal/queue"
 )
 
 // TelemetryClient handles sending metrics to the API server
 type TelemetryClient struct {
 	config    *config.Config
-	collector prometheus.Collector
+	gatherer  prometheus.Gatherer
 	apiClient *api.Client
+	// queue buffers requests while the API cannot be reached, or is nil
+	queue     *queue.Queue
+	metrics   *PrometheusMetrics
+	host      string
 	startTime time.Time
 }
 
-// NewTelemetryClient creates a new telemetry client
-func NewTelemetryClient(cfg *config.Config) *TelemetryClient {
-	// Create API client with circuit breaker and rate limiting
-	apiClient := api.NewClient(api.ClientConfig{
-		RequestTimeout:   10 * time.Second,
-		MaxRetries:       3,
-		RetryDelay:       time.Second,
-		RateLimit:        rate.Limit(20), // 20 requests per second
-		BurstLimit:       5,              // Allow bursts of 5 requests
-		FailureThreshold: 5,              // Open circuit after 5 failures
-		ResetTimeout:     30 * time.Second,
-	})
+// NewTelemetryClient creates a new telemetry client for the API configured
+// in cfg.API that pushes the metrics gathered from gatherer, normally the
+// registry that serves /metrics
+func NewTelemetryClient(cfg *config.Config, gatherer prometheus.Gatherer) (*TelemetryClient, error) {
+	clientConfig, err := apiClientConfig(cfg.API)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+
+	var q *queue.Queue
+	if cfg.API.QueueDir != "" {
+		q, err = queue.Open(queue.Config{
+			Dir:      cfg.API.QueueDir,
+			MaxBytes: int64(cfg.API.QueueMaxSizeMB) << 20,
+			MaxAge:   time.Duration(cfg.Metrics.RetentionDays) * 24 * time.Hour,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open telemetry queue: %w", err)
+		}
+	}
 
 	return &TelemetryClient{
-		config:    cfg,
-		collector: prometheus.NewRegistry(),
-		apiClient: apiClient,
+		config:   cfg,
+		gatherer: gatherer,
+		queue:    q,
+		metrics:  GetPrometheusMetrics(),
+		host:     host,
+		// API client with circuit breaker and rate limiting
+		apiClient: api.NewClient(clientConfig),
 		startTime: time.Now(),
+	}, nil
+}
+
+// apiClientConfig converts the api config section into an api.ClientConfig
+func apiClientConfig(cfg config.APIConfig) (api.ClientConfig, error) {
+	clientConfig, err := retryClientConfig(cfg)
+	if err != nil {
+		return api.ClientConfig{}, err
+	}
+
+	clientConfig.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	clientConfig.Token, err = cfg.ResolveToken()
+	if err != nil {
+		return api.ClientConfig{}, fmt.Errorf("failed to resolve API token: %w", err)
+	}
+	clientConfig.RequestTimeout, err = time.ParseDuration(cfg.RequestTimeout)
+	if err != nil {
+		return api.ClientConfig{}, fmt.Errorf("invalid api.request_timeout: %w", err)
 	}
+	return clientConfig, nil
+}
+
+// retryClientConfig returns an api.ClientConfig holding only the retry, rate
+// limit and circuit breaker settings of the api config section, which the
+// exporters share with the API client
+func retryClientConfig(cfg config.APIConfig) (api.ClientConfig, error) {
+	retryDelay, err := time.ParseDuration(cfg.RetryDelay)
+	if err != nil {
+		return api.ClientConfig{}, fmt.Errorf("invalid api.retry_delay: %w", err)
+	}
+	resetTimeout, err := time.ParseDuration(cfg.ResetTimeout)
+	if err != nil {
+		return api.ClientConfig{}, fmt.Errorf("invalid api.reset_timeout: %w", err)
+	}
+
+	return api.ClientConfig{
+		MaxRetries:       cfg.MaxRetries,
+		RetryDelay:       retryDelay,
+		RateLimit:        rate.Limit(cfg.RateLimit),
+		BurstLimit:       cfg.BurstLimit,
+		FailureThreshold: cfg.FailureThreshold,
+		ResetTimeout:     resetTimeout,
+	}, nil
 }
 
 // CheckinPayload represents the payload for agent check-ins
@@ -63,8 +131,13 @@ func (t *TelemetryClient) Start(ctx context.Context) error {
 	metricsTicker := time.NewTicker(metricsInterval)
 	defer metricsTicker.Stop()
 
-	// Start the check-in loop with fixed 1-minute interval
-	checkinTicker := time.NewTicker(time.Minute)
+	checkinInterval, err := time.ParseDuration(t.config.API.CheckinInterval)
+	if err != nil {
+		checkinInterval = time.Minute // Default interval
+	}
+
+	// Start the check-in loop
+	checkinTicker := time.NewTicker(checkinInterval)
 	defer checkinTicker.Stop()
 
 	// Start the uptime recording loop
@@ -72,48 +145,128 @@ func (t *TelemetryClient) Start(ctx context.Context) error {
 	defer uptimeTicker.Stop()
 
 	logging.Info().
+		Str("api", t.config.API.BaseURL).
 		Str("metrics_interval", metricsInterval.String()).
+		Str("checkin_interval", checkinInterval.String()).
 		Msg("Starting telemetry collection")
 
+	// Check in right away so the API learns about the agent on startup
+	t.checkin(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
 			logging.Info().Msg("Stopping telemetry collection")
 			return ctx.Err()
 		case <-metricsTicker.C:
-			ch := make(chan prometheus.Metric, 100)
-			t.collector.Collect(ch)
-			close(ch)
-
-			// Process collected metrics
-			for metric := range ch {
-				if err := t.sendMetrics(ctx, metric); err != nil {
-					logging.Error().Err(err).Msg("Failed to send metrics")
-				}
+			if err := t.pushMetrics(ctx); err != nil {
+				logging.Error().Err(err).Msg("Failed to send metrics")
 			}
-
-			logging.Debug().Msg("Metrics collected and sent")
 		case <-checkinTicker.C:
-			if err := t.sendCheckin(ctx); err != nil {
-				logging.Error().Err(err).Msg("Failed to send check-in")
-			} else {
-				logging.Debug().Msg("Check-in sent successfully")
-			}
+			t.checkin(ctx)
 		case <-uptimeTicker.C:
 			// Skip uptime recording as we're using Prometheus native metrics
 		}
 	}
 }
 
-// sendMetrics sends metrics to the API server
-func (t *TelemetryClient) sendMetrics(ctx context.Context, metrics prometheus.Metric) error {
-	_, err := t.apiClient.Request(ctx, "POST", "/metrics", metrics)
-	if err != nil {
+// pushMetrics gathers the current metrics and sends them to the API server
+// in a single batch
+func (t *TelemetryClient) pushMetrics(ctx context.Context) error {
+	families := Gather(t.gatherer)
+
+	batch := NewBatch(t.host, families, time.Now())
+	if err := t.sendMetrics(ctx, batch); err != nil {
+		return err
+	}
+
+	logging.Debug().Int("samples", len(batch.Samples)).Msg("Metrics collected and sent")
+	return nil
+}
+
+// sendMetrics sends a batch of metrics to the API server
+func (t *TelemetryClient) sendMetrics(ctx context.Context, batch *Batch) error {
+	if err := t.deliver(ctx, "/metrics", batch); err != nil {
 		return fmt.Errorf("failed to send metrics: %w", err)
 	}
 	return nil
 }
 
+// deliver sends body to path on the API server. When the server cannot be
+// reached the request is queued on disk, and queued requests are replayed
+// before new ones so the server receives them in order.
+func (t *TelemetryClient) deliver(ctx context.Context, path string, body any) error {
+	if t.queue == nil {
+		_, err := t.apiClient.Request(ctx, http.MethodPost, path, body)
+		return err
+	}
+	defer t.recordQueue()
+
+	if t.queue.Len() == 0 {
+		_, err := t.apiClient.Request(ctx, http.MethodPost, path, body)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if qerr := t.queue.Push(path, body); qerr != nil {
+			return fmt.Errorf("%w (failed to queue request: %v)", err, qerr)
+		}
+		return fmt.Errorf("request queued for retry: %w", err)
+	}
+
+	if err := t.queue.Push(path, body); err != nil {
+		return fmt.Errorf("failed to queue request: %w", err)
+	}
+	return t.flush(ctx)
+}
+
+// flush replays queued requests in order until the queue is empty or a
+// request fails. Requests the server rejects permanently are dropped.
+func (t *TelemetryClient) flush(ctx context.Context) error {
+	for {
+		entry, err := t.queue.Peek()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+
+		if _, err := t.apiClient.Request(ctx, http.MethodPost, entry.Path, entry.Body); err != nil {
+			if retryable(err) {
+				return fmt.Errorf("request queued for retry: %w", err)
+			}
+			logging.Warn().Err(err).Str("path", entry.Path).Time("queued", entry.Time).Msg("Dropping queued request rejected by the API")
+		}
+		if err := t.queue.Remove(entry.Seq); err != nil {
+			return err
+		}
+	}
+}
+
+// recordQueue updates the telemetry queue metrics
+func (t *TelemetryClient) recordQueue() {
+	t.metrics.RecordTelemetryQueue(t.queue.Len(), t.queue.OldestAge())
+}
+
+// retryable reports whether a failed request should be queued and sent
+// again, i.e. it did not fail because the server rejected it
+func retryable(err error) bool {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	return true
+}
+
+// checkin sends a check-in and logs the outcome
+func (t *TelemetryClient) checkin(ctx context.Context) {
+	if err := t.sendCheckin(ctx); err != nil {
+		logging.Error().Err(err).Msg("Failed to send check-in")
+	} else {
+		logging.Debug().Msg("Check-in sent successfully")
+	}
+}
+
 // sendCheckin sends a check-in request to the API server
 func (t *TelemetryClient) sendCheckin(ctx context.Context) error {
 	// Get the IP address
@@ -127,8 +280,7 @@ func (t *TelemetryClient) sendCheckin(ctx context.Context) error {
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
 
-	_, err = t.apiClient.Request(ctx, "POST", "/checkin", payload)
-	if err != nil {
+	if err := t.deliver(ctx, "/checkin", payload); err != nil {
 		return fmt.Errorf("failed to send check-in: %w", err)
 	}
 
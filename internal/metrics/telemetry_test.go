@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
+)
+
+func TestAPIClientConfig(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+
+	cfg := config.DefaultConfig().API
+	cfg.BaseURL = "https://talis.example.com/api/"
+	cfg.TokenFile = tokenFile
+	cfg.RequestTimeout = "5s"
+
+	clientConfig, err := apiClientConfig(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "https://talis.example.com/api", clientConfig.BaseURL)
+	require.Equal(t, "file-token", clientConfig.Token)
+	require.Equal(t, 5*time.Second, clientConfig.RequestTimeout)
+	require.Equal(t, time.Second, clientConfig.RetryDelay)
+	require.Equal(t, 30*time.Second, clientConfig.ResetTimeout)
+	require.Equal(t, 3, clientConfig.MaxRetries)
+
+	cfg.TokenFile = filepath.Join(t.TempDir(), "missing")
+	_, err = apiClientConfig(cfg)
+	require.Error(t, err)
+}
+
+func TestTelemetryClientChecksIn(t *testing.T) {
+	checkins := make(chan CheckinPayload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer api-token" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		if r.URL.Path == "/checkin" {
+			var payload CheckinPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode check-in: %v", err)
+			}
+			checkins <- payload
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.API.BaseURL = server.URL
+	cfg.API.Token = "api-token"
+	cfg.API.QueueDir = t.TempDir()
+	client, err := NewTelemetryClient(cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	if _, err := client.getOutboundIP(); err != nil {
+		t.Skipf("no outbound route: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Start(ctx)
+	}()
+
+	// The first check-in is sent on startup
+	select {
+	case payload := <-checkins:
+		require.Equal(t, "alive", payload.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no check-in received")
+	}
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestTelemetryClientPushesOneBatch(t *testing.T) {
+	batches := make(chan Batch, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		var batch Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		batches <- batch
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge"}, []string{"id"})
+	registry.MustRegister(gauge)
+	gauge.WithLabelValues("a").Set(1)
+	gauge.WithLabelValues("b").Set(2)
+
+	cfg := config.DefaultConfig()
+	cfg.API.BaseURL = server.URL
+	cfg.API.QueueDir = ""
+	client, err := NewTelemetryClient(cfg, registry)
+	require.NoError(t, err)
+
+	require.NoError(t, client.pushMetrics(context.Background()))
+	require.Len(t, batches, 1)
+
+	batch := <-batches
+	require.NotEmpty(t, batch.Host)
+	require.Len(t, batch.Samples, 2)
+	require.Equal(t, "test_gauge", batch.Samples[0].Name)
+	require.Equal(t, map[string]string{"id": "a"}, batch.Samples[0].Labels)
+	require.Equal(t, 1.0, batch.Samples[0].Value)
+}
+
+func TestTelemetryClientQueuesWhileAPIIsDown(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload CheckinPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		received <- payload.Status
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.API.BaseURL = server.URL
+	cfg.API.MaxRetries = 0
+	cfg.API.QueueDir = t.TempDir()
+	client, err := NewTelemetryClient(cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.Error(t, client.deliver(ctx, "/checkin", CheckinPayload{Status: "first"}))
+	require.Error(t, client.deliver(ctx, "/checkin", CheckinPayload{Status: "second"}))
+	require.Equal(t, 2, client.queue.Len())
+	require.Equal(t, 2.0, testutil.ToFloat64(client.metrics.queueDepth))
+
+	// A restarted agent picks up the queued requests
+	client, err = NewTelemetryClient(cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.Equal(t, 2, client.queue.Len())
+
+	// Queued requests are replayed before the new one
+	down.Store(false)
+	require.NoError(t, client.deliver(ctx, "/checkin", CheckinPayload{Status: "third"}))
+	require.Zero(t, client.queue.Len())
+	require.Zero(t, testutil.ToFloat64(client.metrics.queueDepth))
+	require.Equal(t, "first", <-received)
+	require.Equal(t, "second", <-received)
+	require.Equal(t, "third", <-received)
+}
+
+func TestTelemetryClientDoesNotQueueRejectedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.API.BaseURL = server.URL
+	cfg.API.MaxRetries = 0
+	cfg.API.QueueDir = t.TempDir()
+	client, err := NewTelemetryClient(cfg, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	require.Error(t, client.deliver(context.Background(), "/checkin", CheckinPayload{Status: "alive"}))
+	require.Zero(t, client.queue.Len())
+}
@@ -0,0 +1,91 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/atomicfile"
+)
+
+// DefaultDir is the directory payloads are stored in by default
+const DefaultDir = "/etc/talis-agent/payload"
+
+// ErrInvalidName is returned when a payload name cannot be used as a file name
+var ErrInvalidName = errors.New("invalid payload name")
+
+// Result describes a payload that has been written to disk
+type Result struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Store writes payloads into a directory on disk
+type Store struct {
+	dir string
+}
+
+// NewStore creates a new Store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
+
+// Dir returns the directory payloads are written to
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save streams r into a temporary file inside the store directory and
+// atomically renames it to name once the whole payload has been written.
+// If name is empty a timestamp based name is generated.
+func (s *Store) Save(name string, r io.Reader) (*Result, error) {
+	name, err := s.resolveName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create payload directory: %w", err)
+	}
+
+	path := filepath.Join(s.dir, name)
+	hash := sha256.New()
+	var size int64
+	err = atomicfile.Write(path, func(w io.Writer) error {
+		var err error
+		size, err = io.Copy(io.MultiWriter(w, hash), r)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write payload: %w", err)
+	}
+
+	return &Result{
+		Path:   path,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// resolveName validates name or generates one when it is empty
+func (s *Store) resolveName(name string) (string, error) {
+	if name == "" {
+		return fmt.Sprintf("payload-%d", time.Now().UTC().UnixNano()), nil
+	}
+
+	if name != filepath.Base(name) || strings.ContainsAny(name, `/\`) ||
+		name == "." || name == ".." || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,73 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	data := "hello talis"
+	result, err := store.Save("greeting.txt", strings.NewReader(data))
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(data))
+	require.Equal(t, filepath.Join(dir, "greeting.txt"), result.Path)
+	require.Equal(t, int64(len(data)), result.Size)
+	require.Equal(t, hex.EncodeToString(sum[:]), result.SHA256)
+
+	content, err := os.ReadFile(result.Path)
+	require.NoError(t, err)
+	require.Equal(t, data, string(content))
+
+	// No temporary files should be left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestSaveGeneratesName(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	result, err := store.Save("", strings.NewReader("data"))
+	require.NoError(t, err)
+	require.Equal(t, dir, filepath.Dir(result.Path))
+	require.True(t, strings.HasPrefix(filepath.Base(result.Path), "payload-"))
+}
+
+func TestSaveInvalidName(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	for _, name := range []string{"../escape", "a/b", ".", "..", ".hidden"} {
+		_, err := store.Save(name, strings.NewReader("data"))
+		require.ErrorIs(t, err, ErrInvalidName, name)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestSaveCleansUpOnError(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	_, err := store.Save("broken", failingReader{})
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,279 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/talis-agent/internal/atomicfile"
+	"github.com/celestiaorg/talis-agent/internal/logging"
+)
+
+// entryExt is the file extension of queue entries
+const entryExt = ".json"
+
+// ErrEntryTooLarge is returned by Push when an entry does not fit in the
+// size limit of the queue on its own
+var ErrEntryTooLarge = errors.New("queue entry is larger than the queue size limit")
+
+// Entry is a queued API request
+type Entry struct {
+	// Seq orders the entries of a queue
+	Seq uint64 `json:"-"`
+	// Time is when the entry was queued
+	Time time.Time `json:"time"`
+	// Path is the API path the request is sent to
+	Path string `json:"path"`
+	// Body is the JSON request body
+	Body json.RawMessage `json:"body"`
+}
+
+// Config configures a Queue
+type Config struct {
+	// Dir is the directory entries are stored in
+	Dir string
+	// MaxBytes bounds the total size of the entries; the oldest entries
+	// are dropped first. Zero means unbounded.
+	MaxBytes int64
+	// MaxAge is how long entries are kept. Zero means forever.
+	MaxAge time.Duration
+}
+
+// item describes an entry file on disk
+type item struct {
+	seq  uint64
+	time time.Time
+	size int64
+}
+
+// Queue is a durable FIFO of API requests. Each entry is stored in its own
+// file, named after its sequence number and queue time, so the queue
+// survives restarts and entries can be dropped without rewriting others.
+type Queue struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	items []item
+	size  int64
+	next  uint64
+}
+
+// Open opens the queue in cfg.Dir, creating the directory if needed, and
+// loads the entries left by a previous run
+func Open(cfg Config) (*Queue, error) {
+	if err := os.MkdirAll(cfg.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &Queue{
+		dir:      cfg.Dir,
+		maxBytes: cfg.MaxBytes,
+		maxAge:   cfg.MaxAge,
+		now:      time.Now,
+	}
+
+	files, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+	for _, file := range files {
+		if atomicfile.IsTemp(file.Name()) && !file.IsDir() {
+			// Left behind by a crash during Push
+			path := filepath.Join(cfg.Dir, file.Name())
+			if err := os.Remove(path); err != nil {
+				logging.Warn().Err(err).Str("path", path).Msg("Failed to remove temporary queue file")
+			}
+			continue
+		}
+		it, ok := parseName(file.Name())
+		if !ok || file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		it.size = info.Size()
+		q.items = append(q.items, it)
+		q.size += it.size
+	}
+	sort.Slice(q.items, func(i, j int) bool {
+		return q.items[i].seq < q.items[j].seq
+	})
+	if len(q.items) > 0 {
+		q.next = q.items[len(q.items)-1].seq + 1
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.enforceLimits()
+	return q, nil
+}
+
+// Push appends a request for path with the JSON encoding of body. The
+// oldest entries are dropped if the queue grows beyond its size limit; an
+// entry that exceeds the limit by itself is rejected with ErrEntryTooLarge.
+func (q *Queue) Push(path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue entry body: %w", err)
+	}
+	entry := Entry{Time: q.now().UTC(), Path: path, Body: data}
+	data, err = json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue entry: %w", err)
+	}
+
+	if q.maxBytes > 0 && int64(len(data)) > q.maxBytes {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrEntryTooLarge, len(data), q.maxBytes)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	it := item{seq: q.next, time: entry.Time, size: int64(len(data))}
+	if err := atomicfile.WriteFile(q.path(it), data); err != nil {
+		return fmt.Errorf("failed to write queue entry: %w", err)
+	}
+	q.next++
+	q.items = append(q.items, it)
+	q.size += it.size
+
+	q.enforceLimits()
+	return nil
+}
+
+// Peek returns the oldest entry without removing it, or nil if the queue is
+// empty. Entries that cannot be read are dropped.
+func (q *Queue) Peek() (*Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.enforceLimits()
+	for len(q.items) > 0 {
+		it := q.items[0]
+		data, err := os.ReadFile(q.path(it))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read queue entry: %w", err)
+		}
+
+		var entry Entry
+		if err == nil {
+			if err = json.Unmarshal(data, &entry); err == nil {
+				entry.Seq = it.seq
+				return &entry, nil
+			}
+		}
+		logging.Warn().Err(err).Str("path", q.path(it)).Msg("Dropping unreadable queue entry")
+		q.drop(0)
+	}
+	return nil, nil
+}
+
+// Remove removes the entry with sequence number seq, typically after it
+// has been delivered
+func (q *Queue) Remove(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, it := range q.items {
+		if it.seq == seq {
+			if err := os.Remove(q.path(it)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove queue entry: %w", err)
+			}
+			q.size -= it.size
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Len returns the number of queued entries
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Size returns the total size of the queued entries in bytes
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// OldestAge returns the age of the oldest entry, or zero if the queue is empty
+func (q *Queue) OldestAge() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return 0
+	}
+	return q.now().Sub(q.items[0].time)
+}
+
+// enforceLimits drops entries that are too old or do not fit in the size
+// limit, oldest first. q.mu must be held.
+func (q *Queue) enforceLimits() {
+	dropped := 0
+	for len(q.items) > 0 {
+		tooOld := q.maxAge > 0 && q.now().Sub(q.items[0].time) > q.maxAge
+		tooBig := q.maxBytes > 0 && q.size > q.maxBytes
+		if !tooOld && !tooBig {
+			break
+		}
+		q.drop(0)
+		dropped++
+	}
+	if dropped > 0 {
+		logging.Warn().Int("entries", dropped).Str("dir", q.dir).Msg("Dropped queue entries over the age or size limit")
+	}
+}
+
+// drop removes the item at index i and its file. q.mu must be held.
+func (q *Queue) drop(i int) {
+	it := q.items[i]
+	if err := os.Remove(q.path(it)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logging.Error().Err(err).Str("path", q.path(it)).Msg("failed to remove queue entry")
+	}
+	q.size -= it.size
+	q.items = append(q.items[:i], q.items[i+1:]...)
+}
+
+// path returns the file path of it
+func (q *Queue) path(it item) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d-%d%s", it.seq, it.time.UnixNano(), entryExt))
+}
+
+// parseName parses an entry file name written by path
+func parseName(name string) (item, bool) {
+	base, ok := strings.CutSuffix(name, entryExt)
+	if !ok {
+		return item{}, false
+	}
+	seqPart, timePart, ok := strings.Cut(base, "-")
+	if !ok {
+		return item{}, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return item{}, false
+	}
+	nanos, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return item{}, false
+	}
+	return item{seq: seq, time: time.Unix(0, nanos).UTC()}, true
+}
@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// drain pops every entry of q and returns their paths in order
+func drain(t *testing.T, q *Queue) []string {
+	t.Helper()
+
+	var paths []string
+	for {
+		entry, err := q.Peek()
+		require.NoError(t, err)
+		if entry == nil {
+			return paths
+		}
+		paths = append(paths, entry.Path)
+		require.NoError(t, q.Remove(entry.Seq))
+	}
+}
+
+func TestQueueFIFO(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	entry, err := q.Peek()
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	require.NoError(t, q.Push("/checkin", map[string]string{"status": "alive"}))
+	require.NoError(t, q.Push("/metrics", map[string]int{"samples": 2}))
+	require.Equal(t, 2, q.Len())
+	require.Positive(t, q.Size())
+
+	entry, err = q.Peek()
+	require.NoError(t, err)
+	require.Equal(t, "/checkin", entry.Path)
+	require.JSONEq(t, `{"status": "alive"}`, string(entry.Body))
+
+	require.Equal(t, []string{"/checkin", "/metrics"}, drain(t, q))
+	require.Zero(t, q.Len())
+	require.Zero(t, q.Size())
+}
+
+func TestQueueSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		require.NoError(t, q.Push(path, nil))
+	}
+	entry, err := q.Peek()
+	require.NoError(t, err)
+	require.NoError(t, q.Remove(entry.Seq))
+
+	q, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.Equal(t, 2, q.Len())
+
+	// New entries are queued after the existing ones
+	require.NoError(t, q.Push("/d", nil))
+	require.Equal(t, []string{"/b", "/c", "/d"}, drain(t, q))
+}
+
+func TestQueueRemovesTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, q.Push("/a", nil))
+
+	// A crash during Push leaves the temporary file of the entry behind
+	tmp := filepath.Join(dir, ".00000000000000000001-1.json.tmp-123456")
+	require.NoError(t, os.WriteFile(tmp, []byte("{"), 0600))
+	other := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(other, nil, 0600))
+
+	q, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.Equal(t, 1, q.Len())
+	require.NoFileExists(t, tmp)
+	require.FileExists(t, other)
+}
+
+func TestQueueSizeLimit(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	// Entries queued at the same time have the same size
+	now := time.Now()
+	q.now = func() time.Time { return now }
+
+	require.NoError(t, q.Push("/a", nil))
+	entrySize := q.Size()
+
+	q.maxBytes = 2 * entrySize
+	require.NoError(t, q.Push("/b", nil))
+	require.NoError(t, q.Push("/c", nil))
+	require.Equal(t, 2, q.Len())
+	require.Equal(t, []string{"/b", "/c"}, drain(t, q))
+}
+
+func TestQueueRejectsEntryLargerThanLimit(t *testing.T) {
+	q, err := Open(Config{Dir: t.TempDir(), MaxBytes: 256})
+	require.NoError(t, err)
+	require.NoError(t, q.Push("/small", nil))
+
+	err = q.Push("/large", strings.Repeat("x", 512))
+	require.ErrorIs(t, err, ErrEntryTooLarge)
+
+	// The queued entries are kept and nothing is left on disk
+	require.Equal(t, []string{"/small"}, drain(t, q))
+	files, err := os.ReadDir(q.dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func TestQueueAgeLimit(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	q, err := Open(Config{Dir: dir, MaxAge: time.Hour})
+	require.NoError(t, err)
+	q.now = func() time.Time { return now }
+
+	require.NoError(t, q.Push("/old", nil))
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, q.Push("/new", nil))
+	require.Equal(t, 30*time.Minute, q.OldestAge())
+
+	now = now.Add(45 * time.Minute)
+	require.Equal(t, []string{"/new"}, drain(t, q))
+}
+
+func TestQueueDropsUnreadableEntries(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, q.Push("/a", nil))
+	require.NoError(t, q.Push("/b", nil))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+entryExt))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.NoError(t, os.WriteFile(files[0], []byte("{not json"), 0600))
+
+	// Unrelated files are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), nil, 0600))
+
+	q, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/b"}, drain(t, q))
+}
@@ -5,12 +5,7 @@ sudo mkdir -p /etc/talis-agent/payload
 
 # Create default configuration file if it doesn't exist
 if [ ! -f /etc/talis-agent/config.yaml ]; then
-    sudo tee /etc/talis-agent/config.yaml > /dev/null << 'EOL'
-http:
-  port: 25550
-logging:
-  level: info
-EOL
+    sudo "${TALIS_AGENT_BIN:-talis-agent}" config init /etc/talis-agent/config.yaml
 fi
 
 # Set appropriate permissions
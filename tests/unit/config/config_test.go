@@ -2,42 +2,42 @@ package config_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
-	"github.com/celestiaorg/talis-agent/config"
-
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/celestiaorg/talis-agent/internal/config"
 )
 
+// writeConfig writes content to a temporary config file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// useConfigPaths points config.Load at paths for the duration of the test
+func useConfigPaths(t *testing.T, paths ...string) {
+	t.Helper()
+
+	config.SetConfigPaths(paths)
+	t.Cleanup(func() {
+		config.SetConfigPaths([]string{"/etc/talis-agent/config.yaml", "config.yaml"})
+	})
+}
+
 func TestLoadConfig(t *testing.T) {
-	// Create a temporary config file
-	tmpfile, err := os.CreateTemp("", "config-*.yaml")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer func() {
-		if err := os.Remove(tmpfile.Name()); err != nil {
-			t.Logf("Error removing temporary file: %v", err)
-		}
-	}()
-
-	// Write test configuration
-	configContent := `
+	path := writeConfig(t, `
 http:
   port: 8080
 logging:
   level: debug
-`
-	if _, err := tmpfile.Write([]byte(configContent)); err != nil {
-		t.Fatal(err)
-	}
-
-	// Temporarily override the config path
-	originalPath := config.ConfigPath
-	config.ConfigPath = tmpfile.Name()
-	defer func() {
-		config.ConfigPath = originalPath
-	}()
+`)
+	useConfigPaths(t, path)
 
 	// Test loading configuration
 	cfg, err := config.Load()
@@ -46,55 +46,70 @@ logging:
 	// Verify configuration values
 	assert.Equal(t, 8080, cfg.HTTP.Port)
 	assert.Equal(t, "debug", cfg.Logging.Level)
+
+	// Fields the file does not set keep their defaults
+	assert.Equal(t, "0.0.0.0", cfg.HTTP.Host)
+	assert.Equal(t, "15s", cfg.Metrics.CollectionInterval)
 }
 
 func TestLoadConfigDefaults(t *testing.T) {
-	// Create a temporary config file with minimal content
-	tmpfile, err := os.CreateTemp("", "config-*.yaml")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer func() {
-		if err := os.Remove(tmpfile.Name()); err != nil {
-			t.Logf("Error removing temporary file: %v", err)
-		}
-	}()
-
-	// Write minimal configuration
-	configContent := `{}`
-	if _, err := tmpfile.Write([]byte(configContent)); err != nil {
-		t.Fatal(err)
+	for _, content := range []string{`{}`, ``} {
+		useConfigPaths(t, writeConfig(t, content))
+
+		// Test loading configuration
+		cfg, err := config.Load()
+		assert.NoError(t, err)
+
+		// Verify default values
+		assert.Equal(t, 25550, cfg.HTTP.Port)
+		assert.Equal(t, "info", cfg.Logging.Level)
 	}
+}
 
-	// Temporarily override the config path
-	originalPath := config.ConfigPath
-	config.ConfigPath = tmpfile.Name()
-	defer func() {
-		config.ConfigPath = originalPath
-	}()
+func TestLoadConfigMissingFile(t *testing.T) {
+	useConfigPaths(t, "/non/existent/path/config.yaml")
 
 	// Test loading configuration
 	cfg, err := config.Load()
 	assert.NoError(t, err)
 
-	// Verify default values
+	// Verify default values are set
 	assert.Equal(t, 25550, cfg.HTTP.Port)
 	assert.Equal(t, "info", cfg.Logging.Level)
 }
 
-func TestLoadConfigMissingFile(t *testing.T) {
-	// Temporarily override the config path to a non-existent file
-	originalPath := config.ConfigPath
-	config.ConfigPath = "/non/existent/path/config.yaml"
-	defer func() {
-		config.ConfigPath = originalPath
-	}()
+func TestLoadConfigSearchOrder(t *testing.T) {
+	first := writeConfig(t, "http:\n  port: 1111\n")
+	second := writeConfig(t, "http:\n  port: 2222\n")
+	useConfigPaths(t, "/non/existent/path/config.yaml", first, second)
 
-	// Test loading configuration
 	cfg, err := config.Load()
-	assert.NoError(t, err)
+	require.NoError(t, err)
+	assert.Equal(t, 1111, cfg.HTTP.Port)
+}
 
-	// Verify default values are set
-	assert.Equal(t, 25550, cfg.HTTP.Port)
-	assert.Equal(t, "info", cfg.Logging.Level)
+func TestLoadConfigUnknownField(t *testing.T) {
+	path := writeConfig(t, `
+http:
+  prot: 8080
+`)
+
+	_, err := config.LoadFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "prot")
+	assert.Contains(t, err.Error(), path)
+}
+
+func TestLoadConfigInvalidYAML(t *testing.T) {
+	_, err := config.LoadFile(writeConfig(t, "http: [port"))
+	require.Error(t, err)
+}
+
+func TestSampleConfigIsGenerated(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "..", "..", "configs", "config.yaml"))
+	require.NoError(t, err)
+
+	template, err := config.Template()
+	require.NoError(t, err)
+	assert.Equal(t, string(template), string(sample), "configs/config.yaml is out of date, run make config")
 }
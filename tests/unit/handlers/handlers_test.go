@@ -1,9 +1,15 @@
 package handlers_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,12 +17,33 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
+	"github.com/celestiaorg/talis-agent/internal/commands"
+	"github.com/celestiaorg/talis-agent/internal/config"
 	"github.com/celestiaorg/talis-agent/internal/handlers"
+	"github.com/celestiaorg/talis-agent/internal/history"
+	agenthttp "github.com/celestiaorg/talis-agent/internal/http"
+	"github.com/celestiaorg/talis-agent/internal/jobs"
 	"github.com/celestiaorg/talis-agent/internal/metrics"
+	"github.com/celestiaorg/talis-agent/internal/payload"
 )
 
-func setupTestApp(t *testing.T) (*fiber.App, *handlers.Handler) {
-	app := fiber.New()
+// newTestApp returns the agent's routes served by a Handler created with
+// opts, reachable without credentials
+func newTestApp(t *testing.T, opts ...handlers.Option) *fiber.App {
+	t.Helper()
+
+	h := handlers.NewHandler(metrics.NewCollector(15*time.Second), opts...)
+	t.Cleanup(h.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.Security.AllowAnonymous = true
+	server, err := agenthttp.NewServer(cfg, h)
+	require.NoError(t, err)
+	return server.App()
+}
+
+// setupTestApp returns the agent's routes with a collector registered for /metrics
+func setupTestApp(t *testing.T) *fiber.App {
 	collector := metrics.NewCollector(15 * time.Second)
 	prometheus.MustRegister(collector)
 
@@ -25,13 +52,11 @@ func setupTestApp(t *testing.T) (*fiber.App, *handlers.Handler) {
 		prometheus.Unregister(collector)
 	})
 
-	h := handlers.NewHandler(collector)
-	return app, h
+	return newTestApp(t)
 }
 
 func TestEndpoints(t *testing.T) {
-	app, h := setupTestApp(t)
-	app.Get("/", h.Endpoints)
+	app := setupTestApp(t)
 
 	req := httptest.NewRequest("GET", "/", nil)
 	resp, err := app.Test(req)
@@ -41,15 +66,17 @@ func TestEndpoints(t *testing.T) {
 	body, err := io.ReadAll(resp.Body)
 	require.NoError(t, err, "Failed to read response body")
 
-	var result map[string][]string
+	var result map[string]json.RawMessage
 	require.NoError(t, json.Unmarshal(body, &result), "Failed to unmarshal response")
 	require.Contains(t, result, "endpoints", "Response missing endpoints key")
-	require.Contains(t, result["endpoints"], "/metrics", "Response missing /metrics endpoint")
+
+	var endpoints []string
+	require.NoError(t, json.Unmarshal(result["endpoints"], &endpoints), "Failed to unmarshal endpoints")
+	require.Contains(t, endpoints, "/metrics", "Response missing /metrics endpoint")
 }
 
 func TestHealthCheck(t *testing.T) {
-	app, h := setupTestApp(t)
-	app.Get("/alive", h.HealthCheck)
+	app := setupTestApp(t)
 
 	req := httptest.NewRequest("GET", "/alive", nil)
 	resp, err := app.Test(req)
@@ -65,8 +92,7 @@ func TestHealthCheck(t *testing.T) {
 }
 
 func TestGetMetrics(t *testing.T) {
-	app, h := setupTestApp(t)
-	app.Get("/metrics", h.GetMetrics)
+	app := setupTestApp(t)
 
 	req := httptest.NewRequest("GET", "/metrics", nil)
 	resp, err := app.Test(req)
@@ -78,9 +104,111 @@ func TestGetMetrics(t *testing.T) {
 	require.Contains(t, string(body), "system_", "Response missing system metrics")
 }
 
+func TestGetMetricsHistory(t *testing.T) {
+	store := history.New(history.Config{})
+	now := time.Now()
+	store.Add([]metrics.Sample{
+		{Name: "system_cpu_usage_percent", Value: 30, TimestampMs: now.Add(-2 * time.Hour).UnixMilli()},
+		{Name: "system_cpu_usage_percent", Value: 10, TimestampMs: now.Add(-2 * time.Minute).UnixMilli()},
+		{Name: "system_cpu_usage_percent", Value: 20, TimestampMs: now.Add(-time.Minute).UnixMilli()},
+	})
+	app := newTestApp(t, handlers.WithHistory(store))
+
+	// The last hour is returned by default
+	req := httptest.NewRequest("GET", "/metrics/history?metric=system_cpu_usage_percent", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+
+	var result struct {
+		Metric string           `json:"metric"`
+		Series []history.Series `json:"series"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Equal(t, "system_cpu_usage_percent", result.Metric)
+	require.Len(t, result.Series, 1)
+	require.Len(t, result.Series[0].Points, 2)
+
+	from := strconv.FormatInt(now.Add(-3*time.Hour).Unix(), 10)
+	req = httptest.NewRequest("GET", "/metrics/history?metric=system_cpu_usage_percent&step=6h&from="+from, nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Equal(t, []history.Point{{TimestampMs: now.Add(-3*time.Hour).Unix() * 1000, Value: 20}}, result.Series[0].Points)
+
+	// Ranges too long for every sample are averaged without an explicit step
+	from = strconv.FormatInt(now.Add(-30*24*time.Hour).Unix(), 10)
+	to := strconv.FormatInt(now.Unix(), 10)
+	req = httptest.NewRequest("GET", "/metrics/history?metric=system_cpu_usage_percent&from="+from+"&to="+to, nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+	var stepped struct {
+		Step   string           `json:"step"`
+		Series []history.Series `json:"series"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stepped), "Failed to decode response")
+	require.Equal(t, "3m56s", stepped.Step)
+	require.Len(t, stepped.Series, 1)
+	require.Len(t, stepped.Series[0].Points, 2)
+}
+
+func TestGetMetricsHistoryInvalidQuery(t *testing.T) {
+	app := newTestApp(t)
+
+	for _, query := range []string{
+		"",
+		"metric=cpu&from=yesterday",
+		"metric=cpu&from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z",
+		"metric=cpu&step=0s",
+		"metric=cpu&step=1ms",
+	} {
+		req := httptest.NewRequest("GET", "/metrics/history?"+query, nil)
+		resp, err := app.Test(req)
+		require.NoError(t, err, "Failed to execute request")
+		require.Equal(t, 400, resp.StatusCode, "Expected status code 400 for %q", query)
+	}
+}
+
+func TestGetSystem(t *testing.T) {
+	app := setupTestApp(t)
+
+	req := httptest.NewRequest("GET", "/system?sections=memory,host_info", nil)
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+
+	var result metrics.SystemMetrics
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.NotNil(t, result.Memory, "Response missing memory section")
+	require.NotNil(t, result.HostInfo, "Response missing host_info section")
+	require.Nil(t, result.CPU, "Expected cpu section to be omitted")
+
+	// CPU usage is omitted until the collector has sampled it
+	req = httptest.NewRequest("GET", "/system", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+	result = metrics.SystemMetrics{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Nil(t, result.CPU, "Expected cpu section to be omitted")
+	require.NotNil(t, result.Disk, "Response missing disk section")
+
+	// unless it is the only section requested
+	req = httptest.NewRequest("GET", "/system?sections=cpu", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 503, resp.StatusCode, "Expected status code 503")
+
+	req = httptest.NewRequest("GET", "/system?sections=gpu", nil)
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 400, resp.StatusCode, "Expected status code 400")
+}
+
 func TestGetIP(t *testing.T) {
-	app, h := setupTestApp(t)
-	app.Get("/ip", h.GetIP)
+	app := setupTestApp(t)
 
 	req := httptest.NewRequest("GET", "/ip", nil)
 	resp, err := app.Test(req)
@@ -95,3 +223,160 @@ func TestGetIP(t *testing.T) {
 	require.Contains(t, result, "ips", "Response missing ips key")
 	require.NotEmpty(t, result["ips"], "Expected non-empty IPs list")
 }
+
+func TestUploadPayload(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(t, handlers.WithPayloadStore(payload.NewStore(dir)))
+
+	data := "genesis contents"
+	req := httptest.NewRequest("POST", "/payload?name=genesis.json", strings.NewReader(data))
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 201, resp.StatusCode, "Expected status code 201")
+
+	var result payload.Result
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Equal(t, filepath.Join(dir, "genesis.json"), result.Path)
+	require.Equal(t, int64(len(data)), result.Size)
+	sum := sha256.Sum256([]byte(data))
+	require.Equal(t, hex.EncodeToString(sum[:]), result.SHA256)
+
+	content, err := os.ReadFile(result.Path)
+	require.NoError(t, err)
+	require.Equal(t, data, string(content))
+}
+
+func TestUploadPayloadInvalidName(t *testing.T) {
+	app := newTestApp(t, handlers.WithPayloadStore(payload.NewStore(t.TempDir())))
+
+	req := httptest.NewRequest("POST", "/payload?name=..", strings.NewReader("data"))
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 400, resp.StatusCode, "Expected status code 400")
+}
+
+func TestExecuteCommand(t *testing.T) {
+	fake := &commands.FakeExecutor{Stdout: "done\n", ExitCode: 0}
+	app := newTestApp(t, handlers.WithExecutor(fake))
+
+	body := `{"argv":["celestia-appd","init","node"],"dir":"/tmp","env":{"HOME":"/root"},"timeout":"30s"}`
+	req := httptest.NewRequest("POST", "/commands", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+
+	var result commands.Result
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "done\n", result.Stdout)
+
+	specs := fake.Specs()
+	require.Len(t, specs, 1)
+	require.Equal(t, []string{"celestia-appd", "init", "node"}, specs[0].Argv)
+	require.Equal(t, "/tmp", specs[0].Dir)
+	require.Equal(t, "/root", specs[0].Env["HOME"])
+}
+
+func TestExecuteCommandPlainScript(t *testing.T) {
+	fake := &commands.FakeExecutor{ExitCode: 2}
+	app := newTestApp(t, handlers.WithExecutor(fake))
+
+	req := httptest.NewRequest("POST", "/commands", strings.NewReader("ls -la"))
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+
+	var result commands.Result
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), "Failed to decode response")
+	require.Equal(t, 2, result.ExitCode)
+	require.Equal(t, "ls -la", fake.Specs()[0].Script)
+}
+
+func TestExecuteCommandInvalidSpec(t *testing.T) {
+	app := newTestApp(t, handlers.WithExecutor(&commands.FakeExecutor{}))
+
+	req := httptest.NewRequest("POST", "/commands", strings.NewReader(`{"timeout":"30s"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 400, resp.StatusCode, "Expected status code 400")
+}
+
+func TestAsyncCommandJob(t *testing.T) {
+	fake := &commands.FakeExecutor{Block: true, Stdout: "downloading\n"}
+	app := newTestApp(t, handlers.WithExecutor(fake))
+
+	req := httptest.NewRequest("POST", "/commands?async=true", strings.NewReader("./setup.sh"))
+	resp, err := app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 202, resp.StatusCode, "Expected status code 202")
+
+	var info jobs.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info), "Failed to decode response")
+	require.Equal(t, jobs.StatusRunning, info.Status)
+	require.Equal(t, "/jobs/"+info.ID, resp.Header.Get("Location"))
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/jobs/"+info.ID, nil))
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+
+	require.Eventually(t, func() bool {
+		resp, err := app.Test(httptest.NewRequest("GET", "/jobs/"+info.ID+"/output", nil))
+		require.NoError(t, err, "Failed to execute request")
+		var output jobs.Output
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&output), "Failed to decode response")
+		return output.Stdout == "downloading\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest("DELETE", "/jobs/"+info.ID, nil))
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info), "Failed to decode response")
+	require.Equal(t, jobs.StatusCanceled, info.Status)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/jobs/unknown", nil))
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 404, resp.StatusCode, "Expected status code 404")
+}
+
+func TestStreamJob(t *testing.T) {
+	fake := &commands.FakeExecutor{Stdout: "step 1\nstep 2\nstep 3\n"}
+	app := newTestApp(t, handlers.WithExecutor(fake))
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/commands?async=true", strings.NewReader("./setup.sh")))
+	require.NoError(t, err, "Failed to execute request")
+	var info jobs.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info), "Failed to decode response")
+
+	require.Eventually(t, func() bool {
+		resp, err := app.Test(httptest.NewRequest("GET", "/jobs/"+info.ID, nil))
+		require.NoError(t, err, "Failed to execute request")
+		var current jobs.Info
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&current), "Failed to decode response")
+		return current.Status.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/jobs/"+info.ID+"/stream", nil))
+	require.NoError(t, err, "Failed to execute request")
+	require.Equal(t, 200, resp.StatusCode, "Expected status code 200")
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "Failed to read response body")
+	require.Contains(t, string(body), "id: 1\nevent: line\n")
+	require.Contains(t, string(body), `"text":"step 3"`)
+	require.Contains(t, string(body), "event: end\n")
+
+	// Resume after the second line
+	req := httptest.NewRequest("GET", "/jobs/"+info.ID+"/stream", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err = app.Test(req)
+	require.NoError(t, err, "Failed to execute request")
+
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err, "Failed to read response body")
+	require.NotContains(t, string(body), `"text":"step 1"`)
+	require.NotContains(t, string(body), `"text":"step 2"`)
+	require.Contains(t, string(body), "id: 3\nevent: line\n")
+}